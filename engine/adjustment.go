@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+)
+
+type AdjustmentKind string
+
+const (
+	PriceOverride AdjustmentKind = "price_override" // Amount is the new unit price of the line
+	LineDiscount  AdjustmentKind = "line_discount"  // Amount is taken off the line
+	OrderDiscount AdjustmentKind = "order_discount" // Amount is taken off the order, Line is ignored
+)
+
+// Adjustment is a manual price override or goodwill discount given by store staff.
+// Every adjustment needs a reason code and the staff member who authorised it, whose role is looked up in the AdjustmentPolicy
+type Adjustment struct {
+	Kind    AdjustmentKind
+	Line    int // Index in Order.Items for line adjustments
+	Amount  float64
+	Reason  string // Reason code, like DAMAGED or PRICE_MATCH
+	StaffID string
+}
+
+// Value is the discount the adjustment gives on the order. Validate rejects price overrides above the price of the line,
+// which would have a negative value and raise the payable
+func (adj Adjustment) Value(order Order) float64 {
+	switch adj.Kind {
+	case PriceOverride:
+		item := order.Items[adj.Line]
+		return (item.Price - adj.Amount) * item.Qty()
+	}
+	return adj.Amount
+}
+
+// Interaction decides how manual adjustments combine with the automatic promotions
+type Interaction string
+
+const (
+	Suppress Interaction = "suppress" // Adjustments replace the promotions, no promotion is applied when an order has adjustments
+	Stack    Interaction = "stack"    // Adjustments are given on top of the best promotion
+	Better   Interaction = "better"   // The bigger of the adjustments and the best promotion is given
+)
+
+// AdjustmentPolicy is how much each role can give and how adjustments interact with promotions.
+// Staff is the role of every staff member by StaffID, the role is never taken from the adjustment itself.
+// Limits is the maximum value of all the adjustments a staff member authorises on one order by their role,
+// so a discount split into several small adjustments has the same limit. Roles that are not in Limits can't adjust prices
+type AdjustmentPolicy struct {
+	Staff       map[string]string
+	Limits      map[string]float64
+	Interaction Interaction
+}
+
+// AppliedAdjustment is an adjustment with the discount it gave, recorded on the pricing result
+type AppliedAdjustment struct {
+	Adjustment
+	Value float64
+}
+
+// Price prices the order with its promotions and adjustments, see PricingPolicy
+func (policy AdjustmentPolicy) Price(order *Order) (PricingResult, error) {
+	return PricingPolicy{Adjustments: policy}.Price(order)
+}
+
+// combine gives the adjustments of the order with the best promotion of the result by the Interaction of the policy.
+// Applied is cleared when the adjustments replace the promotion, so only the promotion that was given is redeemed,
+// and Adjustments is cleared when the promotion is given instead, so only the adjustments that were given are recorded
+func (policy AdjustmentPolicy) combine(order Order, result *PricingResult) error {
+	if len(order.Adjustments) == 0 {
+		return nil
+	}
+	var manual float64
+	for _, adj := range order.Adjustments {
+		value := adj.Value(order)
+		manual += value
+		result.Adjustments = append(result.Adjustments, AppliedAdjustment{Adjustment: adj, Value: value})
+	}
+	result.ManualDiscount = manual
+	switch policy.Interaction {
+	case Suppress:
+		result.Discount = manual
+		result.Applied = PromotionResult{}
+	case Stack:
+		result.Discount += manual
+	default:
+		if manual > result.Discount {
+			result.Discount = manual
+			result.Applied = PromotionResult{}
+		} else {
+			result.Adjustments = nil
+			result.ManualDiscount = 0
+		}
+	}
+	if result.Discount > result.Total {
+		return ValidationErrors{{Field: "Adjustments", Reason: fmt.Sprintf("discount of %.2f is more than the total of %.2f", result.Discount, result.Total)}}
+	}
+	return nil
+}
+
+// Validate checks that every adjustment has a reason and that the adjustments of every staff member add up to no more
+// than the limit of their role. The adjustment that goes over the limit is the one reported
+func (policy AdjustmentPolicy) Validate(order Order) error {
+	var errs ValidationErrors
+	given := make(map[string]float64)
+	over := make(map[string]bool)
+	switch policy.Interaction {
+	case Suppress, Stack, Better, "":
+	default:
+		errs = append(errs, ValidationError{Field: "Interaction", Reason: fmt.Sprintf("unknown interaction %q", policy.Interaction)})
+	}
+	for i, adj := range order.Adjustments {
+		field := fmt.Sprintf("Adjustments[%d]", i)
+		if adj.Reason == "" {
+			errs = append(errs, ValidationError{Field: field + ".Reason", Reason: "reason code is required"})
+		}
+		role, known := policy.Staff[adj.StaffID]
+		if adj.StaffID == "" {
+			errs = append(errs, ValidationError{Field: field + ".StaffID", Reason: "authorising staff member is required"})
+		} else if !known {
+			errs = append(errs, ValidationError{Field: field + ".StaffID", Reason: fmt.Sprintf("unknown staff member %q", adj.StaffID)})
+		}
+		if math.IsNaN(adj.Amount) || adj.Amount < 0 {
+			errs = append(errs, ValidationError{Field: field + ".Amount", Reason: fmt.Sprintf("must not be negative, got %.2f", adj.Amount)})
+			continue
+		}
+		switch adj.Kind {
+		case PriceOverride, LineDiscount:
+			if adj.Line < 0 || adj.Line >= len(order.Items) {
+				errs = append(errs, ValidationError{Field: field + ".Line", Reason: fmt.Sprintf("no line %d in the order", adj.Line)})
+				continue
+			}
+		case OrderDiscount:
+		default:
+			errs = append(errs, ValidationError{Field: field + ".Kind", Reason: fmt.Sprintf("unknown adjustment %q", adj.Kind)})
+			continue
+		}
+		if adj.Kind == LineDiscount {
+			item := order.Items[adj.Line]
+			if adj.Amount > item.Price*item.Qty() {
+				errs = append(errs, ValidationError{Field: field + ".Amount", Reason: fmt.Sprintf("%.2f is more than the line total", adj.Amount)})
+			}
+		}
+		// An override above the price would raise the payable above the total
+		if adj.Kind == PriceOverride && adj.Amount > order.Items[adj.Line].Price {
+			errs = append(errs, ValidationError{Field: field + ".Amount", Reason: fmt.Sprintf("%.2f is more than the price of %.2f", adj.Amount, order.Items[adj.Line].Price)})
+			continue
+		}
+		if !known {
+			continue
+		}
+		limit, ok := policy.Limits[role]
+		if !ok {
+			errs = append(errs, ValidationError{Field: field + ".StaffID", Reason: fmt.Sprintf("%s is a %s, who can't adjust prices", adj.StaffID, role)})
+			continue
+		}
+		given[adj.StaffID] += adj.Value(order)
+		if given[adj.StaffID] > limit && !over[adj.StaffID] {
+			over[adj.StaffID] = true
+			errs = append(errs, ValidationError{Field: field + ".Amount", Reason: fmt.Sprintf("discounts of %s add up to %.2f, over the %.2f limit of %s", adj.StaffID, given[adj.StaffID], limit, role)})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
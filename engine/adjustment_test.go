@@ -0,0 +1,203 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func adjustedOrder(adjustments ...Adjustment) Order {
+	return Order{
+		ID: "1",
+		Items: []Item{
+			{SKU: "A", Price: 400, Amount: 3, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+			{SKU: "B", Price: 100, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		},
+		Promotions: []Promotion{
+			{PromName: "100 Baht off over 1000", PromID: "D100"},
+		},
+		Adjustments: adjustments,
+	}
+}
+
+var (
+	adjustmentLimits = map[string]float64{"cashier": 100, "manager": 1000}
+	adjustmentStaff  = map[string]string{"S1": "cashier", "S2": "manager", "S3": "manager", "S4": "packer"}
+)
+
+func TestAdjustmentPolicy(t *testing.T) {
+	goodwill := Adjustment{Kind: OrderDiscount, Amount: 50, Reason: "GOODWILL", StaffID: "S1"}
+	override := Adjustment{Kind: PriceOverride, Line: 0, Amount: 300, Reason: "PRICE_MATCH", StaffID: "S2"}
+	t.Run("Interactions", func(t *testing.T) {
+		// The total is 1300 so D100 gives 100, the adjustments give 50 and 300
+		cases := []struct {
+			interaction Interaction
+			adjustments []Adjustment
+			discount    float64
+			manual      float64
+		}{
+			{Stack, []Adjustment{goodwill}, 150, 50},
+			{Suppress, []Adjustment{goodwill}, 50, 50},
+			{Better, []Adjustment{goodwill}, 100, 0},
+			{Better, []Adjustment{goodwill, override}, 350, 350},
+		}
+		for _, c := range cases {
+			order := adjustedOrder(c.adjustments...)
+			result, err := AdjustmentPolicy{Limits: adjustmentLimits, Staff: adjustmentStaff, Interaction: c.interaction}.Price(&order)
+			if err != nil {
+				t.Fatalf("%s: expected no error, got %v", c.interaction, err)
+			}
+			if result.Discount != c.discount || result.ManualDiscount != c.manual || order.Discount != c.discount {
+				t.Errorf("%s: expected discount %f with %f manual, got %f with %f", c.interaction, c.discount, c.manual, result.Discount, result.ManualDiscount)
+			}
+			// Only the adjustments that were given are recorded, Better gives D100 instead of the goodwill alone
+			if given := c.manual > 0; given && len(result.Adjustments) != len(c.adjustments) || !given && len(result.Adjustments) != 0 {
+				t.Errorf("%s: expected only the adjustments that were given to be recorded, got %v", c.interaction, result.Adjustments)
+			}
+			if len(order.Given) != len(result.Adjustments) {
+				t.Errorf("%s: expected the given adjustments on the order, got %v", c.interaction, order.Given)
+			}
+		}
+	})
+	t.Run("Price override is recorded with its value", func(t *testing.T) {
+		order := adjustedOrder(override)
+		result, _ := AdjustmentPolicy{Limits: adjustmentLimits, Staff: adjustmentStaff, Interaction: Stack}.Price(&order)
+		// 3 of SKU A from 400 to 300 is 300 off on top of D100
+		if result.Adjustments[0].Value != 300 || result.Adjustments[0].StaffID != "S2" || result.Discount != 400 {
+			t.Errorf("Expected 300 from the override and 400 discount, got %v", result)
+		}
+	})
+	t.Run("Limits and authorisation", func(t *testing.T) {
+		order := adjustedOrder(
+			Adjustment{Kind: OrderDiscount, Amount: 150, Reason: "GOODWILL", StaffID: "S1"},
+			Adjustment{Kind: LineDiscount, Line: 5, Amount: 10, Reason: "DAMAGED", StaffID: "S1"},
+			Adjustment{Kind: OrderDiscount, Amount: 10},
+			Adjustment{Kind: OrderDiscount, Amount: 10, Reason: "GOODWILL", StaffID: "S9"},
+			Adjustment{Kind: OrderDiscount, Amount: 10, Reason: "GOODWILL", StaffID: "S4"},
+		)
+		_, err := AdjustmentPolicy{Limits: adjustmentLimits, Staff: adjustmentStaff, Interaction: Stack}.Price(&order)
+		if !errors.Is(err, ErrInvalidOrder) {
+			t.Fatalf("Expected ErrInvalidOrder, got %v", err)
+		}
+		for _, field := range []string{"Adjustments[0].Amount", "Adjustments[1].Line", "Adjustments[2].Reason", "Adjustments[2].StaffID", "Adjustments[3].StaffID", "Adjustments[4].StaffID"} {
+			if !strings.Contains(err.Error(), field) {
+				t.Errorf("Expected error on %s, got %v", field, err)
+			}
+		}
+	})
+	t.Run("Price override can't be above the price", func(t *testing.T) {
+		order := adjustedOrder(Adjustment{Kind: PriceOverride, Line: 1, Amount: 150, Reason: "PRICE_MATCH", StaffID: "S2"})
+		_, err := AdjustmentPolicy{Limits: adjustmentLimits, Staff: adjustmentStaff, Interaction: Suppress}.Price(&order)
+		if !errors.Is(err, ErrInvalidOrder) || !strings.Contains(err.Error(), "Adjustments[0].Amount") {
+			t.Errorf("Expected ErrInvalidOrder on Adjustments[0].Amount, got %v", err)
+		}
+		if order.Discount != 0 || order.Total != 0 {
+			t.Errorf("Expected the order not to be priced, got %f and %f", order.Total, order.Discount)
+		}
+	})
+	t.Run("Limit is per staff member", func(t *testing.T) {
+		// Two adjustments of 60 are over the 100 limit of the cashier together
+		order := adjustedOrder(Adjustment{Kind: OrderDiscount, Amount: 60, Reason: "GOODWILL", StaffID: "S1"},
+			Adjustment{Kind: LineDiscount, Line: 1, Amount: 60, Reason: "DAMAGED", StaffID: "S1"},
+			Adjustment{Kind: OrderDiscount, Amount: 60, Reason: "GOODWILL", StaffID: "S2"})
+		_, err := AdjustmentPolicy{Limits: adjustmentLimits, Staff: adjustmentStaff, Interaction: Stack}.Price(&order)
+		if !errors.Is(err, ErrInvalidOrder) || !strings.Contains(err.Error(), "Adjustments[1].Amount") || strings.Contains(err.Error(), "Adjustments[2]") {
+			t.Errorf("Expected only Adjustments[1] over the limit, got %v", err)
+		}
+	})
+	t.Run("Discount can't be more than the total", func(t *testing.T) {
+		order := adjustedOrder(Adjustment{Kind: OrderDiscount, Amount: 1000, Reason: "GOODWILL", StaffID: "S2"},
+			Adjustment{Kind: LineDiscount, Line: 1, Amount: 100, Reason: "DAMAGED", StaffID: "S3"},
+			Adjustment{Kind: OrderDiscount, Amount: 300, Reason: "GOODWILL", StaffID: "S3"})
+		_, err := AdjustmentPolicy{Limits: adjustmentLimits, Staff: adjustmentStaff, Interaction: Stack}.Price(&order)
+		if err == nil || !strings.Contains(err.Error(), "more than the total") {
+			t.Errorf("Expected error for 1500 discount on 1300 total, got %v", err)
+		}
+	})
+	t.Run("Every way of pricing combines the adjustments", func(t *testing.T) {
+		// Without a policy that allows them adjustments are rejected instead of ignored
+		order := adjustedOrder(goodwill)
+		if _, err := order.Price(); !errors.Is(err, ErrInvalidOrder) {
+			t.Errorf("Expected ErrInvalidOrder without adjustment limits, got %v", err)
+		}
+		ledger := NewMemoryBudgetLedger(Budget{PromID: "D100", Limit: 60, Mode: BudgetPartial})
+		policy := PricingPolicy{Adjustments: AdjustmentPolicy{Limits: adjustmentLimits, Staff: adjustmentStaff, Interaction: Stack}, Budgets: ledger}
+		result, err := policy.Price(&order)
+		// D100 is limited to the 60 left of its budget and the goodwill is stacked on top
+		if err != nil || result.Discount != 110 || result.Applied.Discount != 60 || order.Discount != 110 {
+			t.Errorf("Expected discount 110 with 60 from D100, got %v and %v", result, err)
+		}
+	})
+	t.Run("Every entry point prices with its policy", func(t *testing.T) {
+		policy := PricingPolicy{Adjustments: AdjustmentPolicy{Limits: adjustmentLimits, Staff: adjustmentStaff, Interaction: Stack}}
+		check := func(entry string, discount float64, err error) {
+			// D100 gives 100 on the total of 1300 and the goodwill of 50 is stacked on top
+			if err != nil || discount != 150 {
+				t.Errorf("%s: expected discount 150, got %f and %v", entry, discount, err)
+			}
+		}
+
+		order := adjustedOrder(goodwill)
+		exp := Experiment{Name: "D100", Variants: []Variant{{Name: "all", Weight: 1, Promotions: order.Promotions}}, Pricing: policy}
+		result, err := exp.Price(&order)
+		check("Experiment", result.Discount, err)
+
+		order = adjustedOrder(goodwill)
+		log, _ := NewAuditLog(&MemoryAuditSink{}, "v1")
+		log.Pricing = policy
+		result, err = log.Price(&order)
+		check("AuditLog", result.Discount, err)
+
+		for batch := range PriceBatch(context.Background(), feed([]Order{adjustedOrder(goodwill)}), BatchOptions{Pricing: policy}) {
+			check("PriceBatch", batch.Order.Discount, batch.Err)
+		}
+		report, err := Simulate([]Order{adjustedOrder(goodwill)}, nil, policy)
+		check("Simulate", report.TotalDiscount, err)
+
+		options, err := CompareTenders(adjustedOrder(goodwill), []Payment{{Method: PayCash}}, policy)
+		if err == nil {
+			check("CompareTenders", options[0].Discount, err)
+		} else {
+			check("CompareTenders", 0, err)
+		}
+
+		expected := Scenario{Order: adjustedOrder(goodwill), Pricing: policy}.Run()
+		check("Scenario", expected.Discount, nil)
+
+		cart := NewCart("1", order.Promotions)
+		cart.Pricing = policy
+		cart.Order.Adjustments = []Adjustment{goodwill}
+		for _, item := range adjustedOrder().Items {
+			err = cart.AddItem(item)
+		}
+		check("Cart", cart.Order.Discount, err)
+	})
+	t.Run("Locking redeems only the applied promotion", func(t *testing.T) {
+		cases := []struct {
+			interaction Interaction
+			redeemed    int
+			reserved    float64
+		}{
+			{Suppress, 0, 0},
+			{Stack, 1, 100},
+		}
+		for _, c := range cases {
+			vouchers := NewMemoryVoucherLedger()
+			ledger := NewMemoryBudgetLedger(Budget{PromID: "D100", Limit: 1000})
+			lc := NewLifecycle(vouchers)
+			lc.Pricing = PricingPolicy{Adjustments: AdjustmentPolicy{Limits: adjustmentLimits, Staff: adjustmentStaff, Interaction: c.interaction}, Budgets: ledger}
+			order := adjustedOrder(goodwill)
+			lc.Price(&order)
+			if err := lc.Lock(&order); err != nil {
+				t.Fatalf("%s: expected no error, got %v", c.interaction, err)
+			}
+			if vouchers.Redemptions("D100") != c.redeemed {
+				t.Errorf("%s: expected %d redemptions of D100, got %d", c.interaction, c.redeemed, vouchers.Redemptions("D100"))
+			}
+			if report := ledger.Report(); report[0].Used != c.reserved {
+				t.Errorf("%s: expected %f of the budget reserved, got %f", c.interaction, c.reserved, report[0].Used)
+			}
+		}
+	})
+}
@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// AllocationStrategy decides how the discount of the order is spread across its lines for the ERP and tax reporting
+type AllocationStrategy string
+
+const (
+	AllocateProportional     AllocationStrategy = ""                  // By line value, every line is rounded to the satang on its own
+	AllocateEligible         AllocationStrategy = "eligible"          // By line value over the lines the applied promotion discounts
+	AllocateLargestRemainder AllocationStrategy = "largest_remainder" // By line value, the satang lost to rounding go to the largest remainders
+)
+
+// LineAllocation is the share of the discount of a line of Order.Items. Net is what the line costs after the discount
+// and UnitNet what a single unit or Unit of weight costs, which is what a partial return refunds
+type LineAllocation struct {
+	Line     int
+	SKU      string
+	Gross    float64
+	Discount float64
+	Net      float64
+	UnitNet  float64
+}
+
+// Refund is the amount refunded when quantity of the line is returned
+func (line LineAllocation) Refund(quantity float64) float64 {
+	return math.Round(line.UnitNet*quantity*100) / 100
+}
+
+// promotionLines maps PromIDs that only discount some lines to a function that returns which lines of the normalized order they discount.
+// Promotions that aren't in the map discount the whole order
+var promotionLines = map[string]func(Promotion, Order) []bool{
+	"B2G1": func(prom Promotion, order Order) []bool { return prom.selectedLines(order, buy2Get1Qualifies) },
+	"B1N1": func(prom Promotion, order Order) []bool { return prom.selectedLines(order, buy1N1BQualifies) },
+	"B2I1": func(prom Promotion, order Order) []bool { return prom.selectedLines(order, freeItemQualifies) },
+	"B1NH": func(prom Promotion, order Order) []bool { return prom.selectedLines(order, fiftyOffQualifies) },
+	"WPCT": func(prom Promotion, order Order) []bool {
+		lines := make([]bool, len(order.Items))
+		for i, item := range order.Items {
+			lines[i] = item.Weighted()
+		}
+		return lines
+	},
+}
+
+// selectedLines marks the line of the unit that the Selection of the promotion picks
+func (prom Promotion) selectedLines(order Order, qualifies func(Item) bool) []bool {
+	lines := make([]bool, len(order.Items))
+	selected, ok := prom.selectItem(order.Items, qualifies)
+	if !ok {
+		return lines
+	}
+	for i, item := range order.Items {
+		if item == selected {
+			lines[i] = true
+			break
+		}
+	}
+	return lines
+}
+
+// Allocate spreads the discount of the order across the lines of Order.Items. The order needs to be priced first.
+// Line discounts and price overrides that the pricing gave stay on their own line, the rest of the discount is spread by the strategy
+// over what is left of every line, so no line is discounted below 0.
+// With AllocateEligible the lines of the applied promotion get its discount, every line does when no promotion was applied
+func (order *Order) Allocate(strategy AllocationStrategy) ([]LineAllocation, error) {
+	switch strategy {
+	case AllocateProportional, AllocateEligible, AllocateLargestRemainder:
+	default:
+		return nil, fmt.Errorf("unknown allocation strategy %q", strategy)
+	}
+	allocations := make([]LineAllocation, len(order.Items))
+	weights := make([]float64, len(order.Items))
+	for i, item := range order.Items {
+		gross := item.Price * item.Qty()
+		allocations[i] = LineAllocation{Line: i, SKU: item.SKU, Gross: gross}
+		weights[i] = gross
+	}
+	rest := order.Discount
+	for _, adj := range order.Given {
+		if adj.Kind == OrderDiscount || adj.Line < 0 || adj.Line >= len(allocations) {
+			continue
+		}
+		allocations[adj.Line].Discount += adj.Value
+		weights[adj.Line] = math.Max(weights[adj.Line]-adj.Value, 0)
+		rest -= adj.Value
+	}
+	largestRemainder := strategy == AllocateLargestRemainder
+	if strategy == AllocateEligible {
+		if eligible := order.eligibleLines(); eligible != nil {
+			// Only the discount of the promotion goes to its lines, order discounts are spread over every line
+			eligibleWeights := make([]float64, len(weights))
+			for i := range weights {
+				if eligible[i] {
+					eligibleWeights[i] = weights[i]
+				}
+			}
+			promotion := math.Min(order.Applied.Discount, rest)
+			if spread(allocations, eligibleWeights, promotion, largestRemainder) {
+				rest -= promotion
+			}
+		}
+	}
+	spread(allocations, weights, rest, largestRemainder)
+	for i, item := range order.Items {
+		allocations[i].Discount = math.Round(allocations[i].Discount*100) / 100
+		allocations[i].Net = allocations[i].Gross - allocations[i].Discount
+		if qty := item.Qty(); qty > 0 {
+			allocations[i].UnitNet = allocations[i].Net / qty
+		}
+	}
+	return allocations, nil
+}
+
+// spread adds the amount to the lines by their weight, false when no line has a weight
+func spread(allocations []LineAllocation, weights []float64, amount float64, largestRemainder bool) bool {
+	var total float64
+	for _, weight := range weights {
+		total += weight
+	}
+	if total <= 0 {
+		return false
+	}
+	if largestRemainder {
+		allocateLargestRemainder(allocations, weights, total, amount)
+		return true
+	}
+	for i := range allocations {
+		allocations[i].Discount += math.Round(amount*weights[i]/total*100) / 100
+	}
+	return true
+}
+
+// eligibleLines returns the lines of Order.Items the applied promotion discounts, nil when every line is
+func (order *Order) eligibleLines() []bool {
+	// The applied promotion is the first one of the breakdown with its result, the same one Best picked
+	applied := -1
+	for i, result := range order.Breakdown {
+		if order.Applied.PromID != "" && result == order.Applied && i < len(order.Promotions) {
+			applied = i
+			break
+		}
+	}
+	if applied < 0 {
+		return nil
+	}
+	prom := order.Promotions[applied]
+	lines, ok := promotionLines[prom.PromID]
+	if !ok {
+		return nil
+	}
+	// The promotion picks from the normalized lines, every original line of a picked line is eligible
+	normalized := Normalize(order.Items)
+	eligible := make([]bool, len(order.Items))
+	for i, picked := range lines(prom, order.normalized()) {
+		if picked {
+			for _, line := range normalized[i].Lines {
+				eligible[line] = true
+			}
+		}
+	}
+	return eligible
+}
+
+// allocateLargestRemainder works in satang so the allocations always add up to the discount
+func allocateLargestRemainder(allocations []LineAllocation, weights []float64, total, discount float64) {
+	satang := int64(math.Round(discount * 100))
+	remainders := make([]float64, len(allocations))
+	order := make([]int, len(allocations))
+	var allocated int64
+	for i := range allocations {
+		exact := float64(satang) * weights[i] / total
+		floor := math.Floor(exact)
+		allocations[i].Discount += floor / 100
+		remainders[i] = exact - floor
+		allocated += int64(floor)
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return remainders[order[a]] > remainders[order[b]] })
+	for _, i := range order {
+		if allocated >= satang {
+			break
+		}
+		if weights[i] == 0 {
+			continue
+		}
+		allocations[i].Discount += 0.01
+		allocations[i].Discount = math.Round(allocations[i].Discount*100) / 100
+		allocated++
+	}
+}
@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAllocate(t *testing.T) {
+	sum := func(allocations []LineAllocation) float64 {
+		var total float64
+		for _, line := range allocations {
+			total += line.Discount
+		}
+		return math.Round(total*100) / 100
+	}
+	t.Run("Proportional to line value", func(t *testing.T) {
+		order := Order{ID: "1", Items: []Item{
+			{SKU: "A", Price: 600, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+			{SKU: "B", Price: 200, Amount: 2, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		}, Promotions: []Promotion{{PromName: "100 Baht off over 1000", PromID: "D100"}}}
+		order.Price()
+		allocations, err := order.Allocate(AllocateProportional)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if allocations[0].Discount != 60 || allocations[1].Discount != 40 || allocations[1].Net != 360 || allocations[1].UnitNet != 180 {
+			t.Errorf("Expected 60 and 40 with 180 per unit of B, got %+v", allocations)
+		}
+		if refund := allocations[1].Refund(1); refund != 180 {
+			t.Errorf("Expected refund of one B to be 180, got %f", refund)
+		}
+	})
+	t.Run("Largest remainder never drifts", func(t *testing.T) {
+		order := Order{ID: "1", Items: []Item{
+			{SKU: "A", Price: 100, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+			{SKU: "B", Price: 100, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+			{SKU: "C", Price: 100, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		}, Promotions: []Promotion{{PromName: "100 Baht off", PromID: "BAHT", Value: 100}}}
+		order.Price()
+		// 33.33 three times loses a satang
+		proportional, _ := order.Allocate(AllocateProportional)
+		if total := sum(proportional); total != 99.99 {
+			t.Errorf("Expected proportional to drift to 99.99, got %f", total)
+		}
+		allocations, _ := order.Allocate(AllocateLargestRemainder)
+		if total := sum(allocations); total != 100 {
+			t.Errorf("Expected the allocations to add up to 100, got %f", total)
+		}
+		if allocations[0].Discount != 33.34 || allocations[1].Discount != 33.33 {
+			t.Errorf("Expected the extra satang on the first line, got %+v", allocations)
+		}
+	})
+	t.Run("Weighted by eligibility", func(t *testing.T) {
+		order := Order{ID: "1", Items: []Item{
+			{SKU: "MANGO", Price: 80, Unit: UnitKg, Quantity: 1.5, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+			{SKU: "RICE", Price: 45, Amount: 2, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+			{SKU: "A", Price: 40, Amount: 2, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+			{SKU: "A", Price: 40, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		}, Promotions: []Promotion{
+			{PromName: "20% off produce", PromID: "WPCT", Percent: 20},
+			{PromName: "Buy2Get1Free", PromID: "B2G1"},
+		}}
+		order.Price()
+		// B2G1 gives 40 on the merged lines of A, WPCT gives 24 on the mango
+		allocations, _ := order.Allocate(AllocateEligible)
+		if allocations[0].Discount != 0 || allocations[1].Discount != 0 {
+			t.Errorf("Expected no discount on mango and rice, got %+v", allocations)
+		}
+		if allocations[2].Discount+allocations[3].Discount != 40 || allocations[2].Discount != 26.67 {
+			t.Errorf("Expected 40 split over the lines of A, got %+v", allocations)
+		}
+	})
+	t.Run("Line adjustments stay on their line", func(t *testing.T) {
+		damaged := Adjustment{Kind: LineDiscount, Line: 1, Amount: 30, Reason: "DAMAGED", StaffID: "S1"}
+		order := adjustedOrder(damaged)
+		PricingPolicy{Adjustments: AdjustmentPolicy{Limits: adjustmentLimits, Staff: adjustmentStaff, Interaction: Stack}}.Price(&order)
+		// 30 off B and the 100 of D100 spread over 1200 of A and the 70 left of B
+		allocations, _ := order.Allocate(AllocateLargestRemainder)
+		if allocations[0].Discount != 94.49 || allocations[1].Discount != 35.51 || sum(allocations) != 130 {
+			t.Errorf("Expected 94.49 on A and 35.51 on B, got %+v", allocations)
+		}
+		// D100 is bigger than the adjustment so it is given instead
+		PricingPolicy{Adjustments: AdjustmentPolicy{Limits: adjustmentLimits, Staff: adjustmentStaff, Interaction: Better}}.Price(&order)
+		allocations, _ = order.Allocate(AllocateProportional)
+		if allocations[1].Discount != 7.69 || sum(allocations) != 100 {
+			t.Errorf("Expected only the share of D100 on B, got %+v", allocations)
+		}
+	})
+	t.Run("No line is discounted below 0", func(t *testing.T) {
+		order := adjustedOrder(Adjustment{Kind: LineDiscount, Line: 1, Amount: 100, Reason: "DAMAGED", StaffID: "S2"},
+			Adjustment{Kind: OrderDiscount, Amount: 100, Reason: "GOODWILL", StaffID: "S2"})
+		PricingPolicy{Adjustments: AdjustmentPolicy{Limits: adjustmentLimits, Staff: adjustmentStaff, Interaction: Suppress}}.Price(&order)
+		for _, strategy := range []AllocationStrategy{AllocateProportional, AllocateEligible, AllocateLargestRemainder} {
+			allocations, _ := order.Allocate(strategy)
+			for _, line := range allocations {
+				if line.Net < 0 || line.UnitNet < 0 {
+					t.Errorf("%q: expected no negative net, got %+v", strategy, line)
+				}
+			}
+			// B is given away by the line discount so the order discount all goes to A
+			if allocations[0].Discount != 100 || allocations[1].Discount != 100 {
+				t.Errorf("%q: expected 100 on A and B, got %+v", strategy, allocations)
+			}
+		}
+	})
+	t.Run("Unknown strategy", func(t *testing.T) {
+		order := Order{ID: "1"}
+		if _, err := order.Allocate("random"); err == nil {
+			t.Errorf("Expected error for unknown strategy")
+		}
+	})
+}
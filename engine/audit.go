@@ -0,0 +1,274 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditRecord is what was considered when an order was priced. Records are chained by hashing the previous hash into the record,
+// so changing or removing a record breaks the hash of every record after it.
+// Hash is the SHA-256 of Raw, the JSON of the record with an empty Hash exactly as it was written. The hash is never computed from
+// the Go struct again, so records written before a field was added to Order or Promotion still verify
+type AuditRecord struct {
+	Seq           uint64
+	Time          time.Time
+	ConfigVersion string // Version of the rules or configuration the order was priced with
+	Order         Order  // The order as it was priced
+	Evaluated     []PromotionResult
+	Winner        string // PromID of the applied promotion, empty when there was no discount
+	Discount      float64
+	Experiment    string
+	Variant       string
+	Error         string // Why the order was rejected, empty when it was priced
+	PrevHash      string
+	Hash          string
+	Raw           json.RawMessage `json:"-"`
+}
+
+// AuditSink stores audit records. Records are only appended, Last is used to continue the hash chain of an existing log
+type AuditSink interface {
+	Append(record AuditRecord) error
+	Last() (AuditRecord, bool, error)
+}
+
+// AuditLog chains and writes a record for every pricing run. It is safe to use from multiple goroutines
+type AuditLog struct {
+	sink    AuditSink
+	version string
+	Now     func() time.Time // Clock of the records, replaced in tests
+	Pricing PricingPolicy    // What Price prices the orders with
+
+	mu   sync.Mutex
+	seq  uint64
+	last string
+}
+
+var ErrAuditChain = errors.New("audit: hash chain is broken")
+
+// NewAuditLog continues the chain from the last record in the sink
+func NewAuditLog(sink AuditSink, configVersion string) (*AuditLog, error) {
+	log := &AuditLog{sink: sink, version: configVersion, Now: time.Now}
+	last, ok, err := sink.Last()
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		log.seq = last.Seq
+		log.last = last.Hash
+	}
+	return log, nil
+}
+
+// Price prices the order with the Pricing policy and records the result. Orders that are rejected are recorded with the error
+func (log *AuditLog) Price(order *Order) (PricingResult, error) {
+	result, err := log.Pricing.Price(order)
+	if err != nil {
+		if _, recordErr := log.record(*order, result, err); recordErr != nil {
+			return result, recordErr
+		}
+		return result, err
+	}
+	_, err = log.Record(*order, result)
+	return result, err
+}
+
+// Record appends the pricing result of the order to the log
+func (log *AuditLog) Record(order Order, result PricingResult) (AuditRecord, error) {
+	return log.record(order, result, nil)
+}
+
+func (log *AuditLog) record(order Order, result PricingResult, pricingErr error) (AuditRecord, error) {
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	record := AuditRecord{
+		Seq:           log.seq + 1,
+		Time:          log.Now().UTC().Round(0),
+		ConfigVersion: log.version,
+		Order:         order,
+		Evaluated:     result.Breakdown,
+		Winner:        result.Applied.PromID,
+		Discount:      result.Discount,
+		Experiment:    result.Experiment,
+		Variant:       result.Variant,
+		PrevHash:      log.last,
+	}
+	if pricingErr != nil {
+		record.Error = pricingErr.Error()
+	}
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return AuditRecord{}, err
+	}
+	record.Raw = raw
+	record.Hash = hashAuditJSON(raw)
+	if err := log.sink.Append(record); err != nil {
+		return AuditRecord{}, err
+	}
+	log.seq = record.Seq
+	log.last = record.Hash
+	return record, nil
+}
+
+func hashAuditJSON(raw []byte) string {
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// emptyAuditHash ends the JSON of every record, Hash is the last field and is empty when the record is hashed
+var emptyAuditHash = []byte(`"Hash":""}`)
+
+// line is the JSON line that is written, the hashed JSON with the hash filled in
+func (record AuditRecord) line() ([]byte, error) {
+	if !bytes.HasSuffix(record.Raw, emptyAuditHash) {
+		return nil, fmt.Errorf("audit: record %d has no hashed JSON, records are created by an AuditLog", record.Seq)
+	}
+	line := append([]byte(nil), record.Raw[:len(record.Raw)-len(emptyAuditHash)]...)
+	return append(line, `"Hash":"`+record.Hash+`"}`...), nil
+}
+
+// verify checks the hash against the raw JSON and that the fields of the record are still what the raw JSON says
+func (record AuditRecord) verify() bool {
+	if len(record.Raw) == 0 || hashAuditJSON(record.Raw) != record.Hash {
+		return false
+	}
+	var stored AuditRecord
+	if err := json.Unmarshal(record.Raw, &stored); err != nil {
+		return false
+	}
+	record.Hash, record.Raw = "", nil
+	fields, err := json.Marshal(record)
+	if err != nil {
+		return false
+	}
+	storedFields, err := json.Marshal(stored)
+	return err == nil && bytes.Equal(fields, storedFields)
+}
+
+// VerifyAuditChain checks that every record links to the one before it and that no record was changed
+func VerifyAuditChain(records []AuditRecord) error {
+	prev := ""
+	for i, record := range records {
+		if i > 0 && record.Seq != records[i-1].Seq+1 {
+			return fmt.Errorf("%w: record %d follows %d", ErrAuditChain, record.Seq, records[i-1].Seq)
+		}
+		if i > 0 && record.PrevHash != prev {
+			return fmt.Errorf("%w: record %d doesn't link to the previous record", ErrAuditChain, record.Seq)
+		}
+		if !record.verify() {
+			return fmt.Errorf("%w: record %d was modified", ErrAuditChain, record.Seq)
+		}
+		prev = record.Hash
+	}
+	return nil
+}
+
+// MemoryAuditSink keeps the records in memory, for tests and short lived processes
+type MemoryAuditSink struct {
+	mu      sync.Mutex
+	records []AuditRecord
+}
+
+func (sink *MemoryAuditSink) Append(record AuditRecord) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	sink.records = append(sink.records, record)
+	return nil
+}
+
+func (sink *MemoryAuditSink) Last() (AuditRecord, bool, error) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.records) == 0 {
+		return AuditRecord{}, false, nil
+	}
+	return sink.records[len(sink.records)-1], true, nil
+}
+
+// Records returns a copy of the records in the order they were appended
+func (sink *MemoryAuditSink) Records() []AuditRecord {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	return append([]AuditRecord(nil), sink.records...)
+}
+
+// JSONLAuditSink appends one JSON record per line to a file. The file is opened in append mode so existing records are never rewritten
+type JSONLAuditSink struct {
+	mu   sync.Mutex
+	file *os.File
+	last *AuditRecord
+}
+
+func OpenJSONLAuditSink(path string) (*JSONLAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	records, err := ReadAuditRecords(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	sink := &JSONLAuditSink{file: file}
+	if len(records) > 0 {
+		sink.last = &records[len(records)-1]
+	}
+	return sink, nil
+}
+
+func (sink *JSONLAuditSink) Append(record AuditRecord) error {
+	data, err := record.line()
+	if err != nil {
+		return err
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if _, err := sink.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	sink.last = &record
+	return nil
+}
+
+func (sink *JSONLAuditSink) Last() (AuditRecord, bool, error) {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if sink.last == nil {
+		return AuditRecord{}, false, nil
+	}
+	return *sink.last, true, nil
+}
+
+func (sink *JSONLAuditSink) Close() error {
+	return sink.file.Close()
+}
+
+// ReadAuditRecords reads a JSON-lines audit log, the records can be checked with VerifyAuditChain.
+// Every record keeps the JSON it was hashed from in Raw
+func ReadAuditRecords(r io.Reader) ([]AuditRecord, error) {
+	var records []AuditRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("audit record %d: %w", len(records)+1, err)
+		}
+		// The hashed JSON is the line with the hash emptied again, a line that doesn't end with its hash fails VerifyAuditChain
+		line := scanner.Bytes()
+		record.Raw = append(json.RawMessage(nil), line...)
+		if hash := []byte(`"Hash":"` + record.Hash + `"}`); bytes.HasSuffix(line, hash) {
+			record.Raw = append(record.Raw[:len(line)-len(hash)], emptyAuditHash...)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
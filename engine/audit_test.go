@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func auditOrder(id string) Order {
+	return Order{
+		ID: id,
+		Items: []Item{
+			{SKU: "A", Price: 600, Amount: 3, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		},
+		Promotions: []Promotion{
+			{PromName: "Buy2Get1Free", PromID: "B2G1"},
+			{PromName: "100 Baht off over 1000", PromID: "D100"},
+		},
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	t.Run("Records every promotion and the winner", func(t *testing.T) {
+		sink := &MemoryAuditSink{}
+		log, _ := NewAuditLog(sink, "v1")
+		log.Now = func() time.Time { return time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC) }
+		order := auditOrder("1")
+		log.Price(&order)
+		order = auditOrder("2")
+		log.Price(&order)
+
+		records := sink.Records()
+		if len(records) != 2 {
+			t.Fatalf("Expected 2 records, got %d", len(records))
+		}
+		// Buy2Get1Free gives 600 and 100 Baht off gives 100, Buy2Get1Free wins
+		first := records[0]
+		if first.Winner != "B2G1" || first.Discount != 600 || len(first.Evaluated) != 2 || first.Evaluated[1].Discount != 100 {
+			t.Errorf("Expected B2G1 to win with 600 discount, got %v", first)
+		}
+		if first.ConfigVersion != "v1" || first.Seq != 1 || records[1].PrevHash != first.Hash {
+			t.Errorf("Expected version v1 and the second record chained to the first, got %v", records)
+		}
+		if err := VerifyAuditChain(records); err != nil {
+			t.Errorf("Expected valid chain, got %v", err)
+		}
+	})
+	t.Run("Tampering breaks the chain", func(t *testing.T) {
+		sink := &MemoryAuditSink{}
+		log, _ := NewAuditLog(sink, "v1")
+		for _, id := range []string{"1", "2", "3"} {
+			order := auditOrder(id)
+			log.Price(&order)
+		}
+		records := sink.Records()
+		records[1].Discount = 0
+		if err := VerifyAuditChain(records); !errors.Is(err, ErrAuditChain) {
+			t.Errorf("Expected ErrAuditChain for modified record, got %v", err)
+		}
+		records = sink.Records()
+		if err := VerifyAuditChain(append(records[:1], records[2])); !errors.Is(err, ErrAuditChain) {
+			t.Errorf("Expected ErrAuditChain for removed record, got %v", err)
+		}
+	})
+	t.Run("JSON lines file continues the chain when reopened", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		for _, id := range []string{"1", "2"} {
+			sink, err := OpenJSONLAuditSink(path)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			log, _ := NewAuditLog(sink, "v1")
+			order := auditOrder(id)
+			if _, err := log.Price(&order); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			sink.Close()
+		}
+		file, _ := os.Open(path)
+		defer file.Close()
+		records, err := ReadAuditRecords(file)
+		if err != nil || len(records) != 2 {
+			t.Fatalf("Expected 2 records, got %d and %v", len(records), err)
+		}
+		if err := VerifyAuditChain(records); err != nil {
+			t.Errorf("Expected valid chain, got %v", err)
+		}
+	})
+	t.Run("Logs of older builds still verify", func(t *testing.T) {
+		// Written before Order had CreatedAt and Promotion had Selection, the hash covers the JSON that was written
+		data, err := os.ReadFile("testdata/audit/v1.jsonl")
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := ReadAuditRecords(bytes.NewReader(data))
+		if err != nil || len(records) != 2 {
+			t.Fatalf("Expected 2 records, got %d and %v", len(records), err)
+		}
+		if err := VerifyAuditChain(records); err != nil {
+			t.Errorf("Expected valid chain, got %v", err)
+		}
+		tampered := strings.Replace(string(data), `"Winner":"B2G1","Discount":600`, `"Winner":"B2G1","Discount":500`, 1)
+		records, _ = ReadAuditRecords(strings.NewReader(tampered))
+		if err := VerifyAuditChain(records); !errors.Is(err, ErrAuditChain) {
+			t.Errorf("Expected ErrAuditChain for a modified line, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// BatchOptions is for repricing a set of orders. When Promotions is set it replaces the promotions of every order,
+// which is how historical orders are repriced when a campaign changes. The slice is shared by all workers and is only read.
+// Every order is priced with the Pricing policy, its budget ledger is shared by the workers.
+type BatchOptions struct {
+	Workers    int // Number of orders priced at the same time, defaults to the number of CPUs
+	Promotions []Promotion
+	Pricing    PricingPolicy
+}
+
+// BatchResult is the priced order. Index is the position of the order in the input since results are streamed back as soon as they are done
+type BatchResult struct {
+	Index int
+	Order Order
+	Err   error
+}
+
+// PriceBatch prices every order from the channel on a bounded pool of workers and streams the results back.
+// The result channel is closed once the input is drained or the context is cancelled. Orders that were received
+// but not priced before cancellation are reported with the error of the context.
+// CalcTotal and CalcDiscount only write to the order they are called on, so each worker works on its own copy of the order.
+func PriceBatch(ctx context.Context, orders <-chan Order, opts BatchOptions) <-chan BatchResult {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	type job struct {
+		index int
+		order Order
+	}
+	jobs := make(chan job)
+	results := make(chan BatchResult, workers)
+
+	go func() {
+		defer close(jobs)
+		index := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case order, ok := <-orders:
+				if !ok {
+					return
+				}
+				select {
+				case jobs <- job{index: index, order: order}:
+				case <-ctx.Done():
+					results <- BatchResult{Index: index, Order: order, Err: ctx.Err()}
+					return
+				}
+				index++
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				result := BatchResult{Index: j.index, Order: j.order}
+				if err := ctx.Err(); err != nil {
+					result.Err = err
+				} else {
+					result.Err = priceBatchOrder(&result.Order, opts)
+				}
+				results <- result
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// priceBatchOrder reprices the order from scratch with the Pricing policy, which resets the discount. CalcDiscount keeps the existing discount
+// if it is higher, which would leave the discount of the old campaign on historical orders.
+// Historical orders are usually paid, and the pricing of a locked order is frozen, so the order is priced as a draft
+// and gets its state back on the result. Only the copy of the worker is changed, never the order of the caller.
+// A panic in one promotion is reported as the error of the order so it doesn't stop the rest of the batch.
+func priceBatchOrder(order *Order, opts BatchOptions) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("pricing order %s: %v", order.ID, r)
+		}
+	}()
+	if opts.Promotions != nil {
+		order.Promotions = opts.Promotions
+	}
+	state := order.State
+	order.State = StateDraft
+	defer func() { order.State = state }()
+	_, err = opts.Pricing.Price(order)
+	return err
+}
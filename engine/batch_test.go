@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func batchOrders(n int) []Order {
+	orders := make([]Order, n)
+	for i := range orders {
+		orders[i] = Order{
+			ID: fmt.Sprint(i),
+			Items: []Item{
+				{SKU: "A", Price: float64(100 * (i%5 + 1)), Amount: int64(i%4 + 1), ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+				{SKU: "B", Price: 30, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: true},
+			},
+			Promotions: []Promotion{
+				{PromName: "Buy2Get1Free", PromID: "B2G1"},
+			},
+			// Discount of the old campaign that should be replaced when repricing
+			Discount: 5000,
+		}
+	}
+	return orders
+}
+
+func feed(orders []Order) <-chan Order {
+	in := make(chan Order)
+	go func() {
+		defer close(in)
+		for _, order := range orders {
+			in <- order
+		}
+	}()
+	return in
+}
+
+func TestPriceBatch(t *testing.T) {
+	t.Run("Same result as pricing one by one", func(t *testing.T) {
+		orders := batchOrders(200)
+		promotions := []Promotion{
+			{PromName: "Buy2Get1Free", PromID: "B2G1"},
+			{PromName: "100 Baht off over 1000", PromID: "D100"},
+			{PromName: "Buy 1 get 15%, Buy 2 get 20%, Buy 3 get 30%", PromID: "INCD"},
+		}
+		seen := make(map[int]bool)
+		for result := range PriceBatch(context.Background(), feed(orders), BatchOptions{Workers: 4, Promotions: promotions}) {
+			if result.Err != nil {
+				t.Fatalf("Expected no error, got %v", result.Err)
+			}
+			seen[result.Index] = true
+			expected := orders[result.Index]
+			expected.Promotions = promotions
+			expected.Discount = 0
+			expected.CalcTotal()
+			expected.CalcDiscount()
+			if result.Order.Total != expected.Total || result.Order.Discount != expected.Discount {
+				t.Errorf("Order %d: expected %f/%f, got %f/%f", result.Index, expected.Total, expected.Discount, result.Order.Total, result.Order.Discount)
+			}
+		}
+		if len(seen) != len(orders) {
+			t.Errorf("Expected %d results, got %d", len(orders), len(seen))
+		}
+	})
+	t.Run("Keeps the promotions of the order without a shared rule set", func(t *testing.T) {
+		for result := range PriceBatch(context.Background(), feed(batchOrders(1)), BatchOptions{}) {
+			// 100 Baht with 1 amount, Buy2Get1Free isn't applicable and the old discount is cleared
+			if result.Order.Discount != 0 {
+				t.Errorf("Expected discount to be 0, got %f", result.Order.Discount)
+			}
+		}
+	})
+	t.Run("Reprices paid and refunded orders", func(t *testing.T) {
+		orders := batchOrders(3)
+		orders[1].State = StatePaid
+		orders[2].State = StateRefunded
+		for result := range PriceBatch(context.Background(), feed(orders), BatchOptions{Promotions: []Promotion{{PromName: "50% Off", PromID: "HOFF"}}}) {
+			if result.Err != nil {
+				t.Fatalf("Order %d: expected no error, got %v", result.Index, result.Err)
+			}
+			if expected := result.Order.Total * 0.5; result.Order.Discount != expected {
+				t.Errorf("Order %d: expected discount to be %f, got %f", result.Index, expected, result.Order.Discount)
+			}
+			if result.Order.State != orders[result.Index].State {
+				t.Errorf("Order %d: expected state %q to be kept, got %q", result.Index, orders[result.Index].State, result.Order.State)
+			}
+		}
+	})
+	t.Run("Cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		priced := 0
+		for result := range PriceBatch(ctx, feed(batchOrders(50)), BatchOptions{Workers: 2}) {
+			if result.Err == nil {
+				priced++
+			}
+		}
+		if priced != 0 {
+			t.Errorf("Expected no orders to be priced after cancellation, got %d", priced)
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+)
+
+// BudgetMode decides what happens to a promotion whose discount is more than its remaining budget
+type BudgetMode string
+
+const (
+	BudgetDisable BudgetMode = ""        // The promotion gives no discount once the budget can't cover it
+	BudgetPartial BudgetMode = "partial" // The discount is scaled back to what is left of the budget
+)
+
+// Budget is the total discount a promotion can give across all orders, like HOFF until 200,000 Baht of discount
+type Budget struct {
+	PromID string
+	Limit  float64
+	Mode   BudgetMode
+}
+
+// BudgetStatus is the spend of a budget for reporting. Used is the discount reserved by locked and paid orders
+type BudgetStatus struct {
+	Budget
+	Used      float64
+	Remaining float64
+	Orders    int
+}
+
+var ErrBudgetExhausted = errors.New("promotion budget exhausted")
+
+// BudgetLedger tracks the spend of promotion budgets across orders. The discount of the applied promotion is reserved
+// when the order is locked, and released when it is cancelled. Reserve must fail without reserving anything when the
+// amount is more than the remaining budget, so two orders can't spend the same Baht.
+type BudgetLedger interface {
+	Remaining(promID string) (remaining float64, mode BudgetMode, ok bool) // ok is false for promotions without a budget
+	Reserve(orderID, promID string, amount float64) error
+	Release(orderID string) error
+}
+
+// limitToBudget lowers the discounts of the breakdown to what the budgets can still cover
+func limitToBudget(ledger BudgetLedger, breakdown []PromotionResult) []PromotionResult {
+	limited := make([]PromotionResult, len(breakdown))
+	for i, prom := range breakdown {
+		limited[i] = prom
+		remaining, mode, ok := ledger.Remaining(prom.PromID)
+		if !ok || toSatang(prom.Discount) <= toSatang(remaining) {
+			continue
+		}
+		if mode == BudgetPartial && remaining > 0 {
+			limited[i].Discount = remaining
+			limited[i].Reason = fmt.Sprintf("limited to the remaining budget of %.2f", remaining)
+		} else {
+			limited[i].Discount = 0
+			limited[i].Reason = fmt.Sprintf("budget exhausted, %.2f left", remaining)
+		}
+	}
+	return limited
+}
+
+// PriceWithBudgets prices the order with the discount of every promotion limited to its remaining budget.
+// Nothing is reserved, the budget is only spent when the order is locked
+func PriceWithBudgets(order *Order, ledger BudgetLedger) (PricingResult, error) {
+	return PricingPolicy{Budgets: ledger}.Price(order)
+}
+
+// toSatang is how budgets are compared and added up, so 0.1 and 0.2 reserved from a limit of 0.3 use it up exactly
+func toSatang(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// MemoryBudgetLedger keeps the budgets and their reservations in memory. Reservations are kept in satang
+type MemoryBudgetLedger struct {
+	mu           sync.Mutex
+	budgets      map[string]Budget
+	reservations map[string]map[string]int64 // Satang reserved by each order for each PromID
+	used         map[string]int64            // Total of the reservations of each PromID, kept up to date by Reserve and Release
+}
+
+func NewMemoryBudgetLedger(budgets ...Budget) *MemoryBudgetLedger {
+	ledger := &MemoryBudgetLedger{budgets: make(map[string]Budget), reservations: make(map[string]map[string]int64), used: make(map[string]int64)}
+	for _, budget := range budgets {
+		ledger.budgets[budget.PromID] = budget
+	}
+	return ledger
+}
+
+// SetBudget adds a budget or changes the limit of an existing one. What was already reserved stays reserved
+func (ledger *MemoryBudgetLedger) SetBudget(budget Budget) {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+	ledger.budgets[budget.PromID] = budget
+}
+
+func (ledger *MemoryBudgetLedger) Remaining(promID string) (float64, BudgetMode, bool) {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+	budget, ok := ledger.budgets[promID]
+	if !ok {
+		return 0, "", false
+	}
+	return float64(toSatang(budget.Limit)-ledger.used[promID]) / 100, budget.Mode, true
+}
+
+func (ledger *MemoryBudgetLedger) Reserve(orderID, promID string, amount float64) error {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+	budget, ok := ledger.budgets[promID]
+	satang := toSatang(amount)
+	if !ok || satang <= 0 {
+		return nil
+	}
+	if remaining := toSatang(budget.Limit) - ledger.used[promID]; satang > remaining {
+		return fmt.Errorf("%w: %s has %.2f left, order %s needs %.2f", ErrBudgetExhausted, promID, float64(remaining)/100, orderID, amount)
+	}
+	if ledger.reservations[orderID] == nil {
+		ledger.reservations[orderID] = make(map[string]int64)
+	}
+	ledger.reservations[orderID][promID] += satang
+	ledger.used[promID] += satang
+	return nil
+}
+
+func (ledger *MemoryBudgetLedger) Release(orderID string) error {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+	for promID, amount := range ledger.reservations[orderID] {
+		ledger.used[promID] -= amount
+	}
+	delete(ledger.reservations, orderID)
+	return nil
+}
+
+// Report returns the status of every budget sorted by PromID
+func (ledger *MemoryBudgetLedger) Report() []BudgetStatus {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+	report := make([]BudgetStatus, 0, len(ledger.budgets))
+	for promID, budget := range ledger.budgets {
+		status := BudgetStatus{Budget: budget, Used: float64(ledger.used[promID]) / 100}
+		status.Remaining = float64(toSatang(budget.Limit)-ledger.used[promID]) / 100
+		for _, reserved := range ledger.reservations {
+			if _, ok := reserved[promID]; ok {
+				status.Orders++
+			}
+		}
+		report = append(report, status)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].PromID < report[j].PromID })
+	return report
+}
+
+// PrintBudgets writes the report as a table
+func PrintBudgets(w io.Writer, report []BudgetStatus) {
+	fmt.Fprintf(w, "%-8s %12s %12s %12s %7s %6s\n", "PromID", "Limit", "Used", "Remaining", "Used %", "Orders")
+	for _, status := range report {
+		var percent float64
+		if status.Limit > 0 {
+			percent = status.Used / status.Limit * 100
+		}
+		fmt.Fprintf(w, "%-8s %12.2f %12.2f %12.2f %6.1f%% %6d\n", status.PromID, status.Limit, status.Used, status.Remaining, percent, status.Orders)
+	}
+}
@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func budgetOrder(id string) Order {
+	return Order{
+		ID: id,
+		Items: []Item{
+			{SKU: "A", Price: 300, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		},
+		Promotions: []Promotion{
+			{PromName: "50% Off", PromID: "HOFF"},
+		},
+	}
+}
+
+func TestBudget(t *testing.T) {
+	t.Run("Budget is spent by locked orders", func(t *testing.T) {
+		ledger := NewMemoryBudgetLedger(Budget{PromID: "HOFF", Limit: 400})
+		lc := NewLifecycle(nil)
+		lc.Pricing.Budgets = ledger
+		first := budgetOrder("1")
+		lc.Price(&first)
+		if err := lc.Lock(&first); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		// 250 is left, which can't cover the 150 discount of the second order and the third at the same time
+		second := budgetOrder("2")
+		result, _ := lc.Price(&second)
+		if result.Discount != 150 {
+			t.Errorf("Expected discount to be 150, got %f", result.Discount)
+		}
+		lc.Lock(&second)
+		third := budgetOrder("3")
+		result, _ = lc.Price(&third)
+		if result.Discount != 0 {
+			t.Errorf("Expected discount to be 0, got %f", result.Discount)
+		}
+		if result.Breakdown[0].Reason == "" {
+			t.Errorf("Expected a reason for the exhausted budget")
+		}
+		report := ledger.Report()
+		if report[0].Used != 300 || report[0].Remaining != 100 || report[0].Orders != 2 {
+			t.Errorf("Expected 300 used, 100 remaining by 2 orders, got %+v", report[0])
+		}
+	})
+	t.Run("Partial discount from the remaining budget", func(t *testing.T) {
+		ledger := NewMemoryBudgetLedger(Budget{PromID: "HOFF", Limit: 200, Mode: BudgetPartial})
+		ledger.Reserve("0", "HOFF", 120)
+		order := budgetOrder("1")
+		result, err := PriceWithBudgets(&order, ledger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Discount != 80 || order.Discount != 80 {
+			t.Errorf("Expected discount to be 80, got %f", result.Discount)
+		}
+	})
+	t.Run("Cancel releases the budget", func(t *testing.T) {
+		ledger := NewMemoryBudgetLedger(Budget{PromID: "HOFF", Limit: 150})
+		lc := NewLifecycle(nil)
+		lc.Pricing.Budgets = ledger
+		order := budgetOrder("1")
+		lc.Price(&order)
+		lc.Lock(&order)
+		lc.Cancel(&order)
+		if remaining, _, _ := ledger.Remaining("HOFF"); remaining != 150 {
+			t.Errorf("Expected remaining to be 150, got %f", remaining)
+		}
+	})
+	t.Run("Release gives back the reservations of the order only", func(t *testing.T) {
+		ledger := NewMemoryBudgetLedger(Budget{PromID: "HOFF", Limit: 500}, Budget{PromID: "D100", Limit: 500})
+		ledger.Reserve("1", "HOFF", 150)
+		ledger.Reserve("1", "D100", 100)
+		ledger.Reserve("2", "HOFF", 200)
+		ledger.Release("1")
+		if remaining, _, _ := ledger.Remaining("HOFF"); remaining != 300 {
+			t.Errorf("Expected 300 of HOFF remaining, got %f", remaining)
+		}
+		if remaining, _, _ := ledger.Remaining("D100"); remaining != 500 {
+			t.Errorf("Expected 500 of D100 remaining, got %f", remaining)
+		}
+	})
+	t.Run("Reservations up to the exact limit", func(t *testing.T) {
+		// 0.1 + 0.2 is more than 0.3 in float64, in satang it is the whole budget
+		ledger := NewMemoryBudgetLedger(Budget{PromID: "HOFF", Limit: 0.3})
+		if err := ledger.Reserve("1", "HOFF", 0.1); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if err := ledger.Reserve("2", "HOFF", 0.2); err != nil {
+			t.Fatalf("Expected the last 0.2 to be reserved, got %v", err)
+		}
+		if err := ledger.Reserve("3", "HOFF", 0.01); !errors.Is(err, ErrBudgetExhausted) {
+			t.Errorf("Expected ErrBudgetExhausted past the limit, got %v", err)
+		}
+		if report := ledger.Report(); report[0].Used != 0.3 || report[0].Remaining != 0 {
+			t.Errorf("Expected 0.3 used and nothing remaining, got %+v", report[0])
+		}
+		// A discount of exactly what is left isn't limited
+		ledger.Release("2")
+		order := Order{ID: "4", Items: []Item{{SKU: "A", Price: 0.4, Amount: 1}}, Promotions: []Promotion{{PromName: "50% Off", PromID: "HOFF"}}}
+		if result, _ := PriceWithBudgets(&order, ledger); result.Discount != 0.2 || result.Breakdown[0].Reason != "" {
+			t.Errorf("Expected the 0.2 of HOFF to fit the 0.2 left, got %+v", result)
+		}
+	})
+	t.Run("Budget spent after pricing fails the lock", func(t *testing.T) {
+		ledger := NewMemoryBudgetLedger(Budget{PromID: "HOFF", Limit: 150})
+		lc := NewLifecycle(nil)
+		lc.Pricing.Budgets = ledger
+		first, second := budgetOrder("1"), budgetOrder("2")
+		lc.Price(&first)
+		lc.Price(&second)
+		lc.Lock(&first)
+		if err := lc.Lock(&second); !errors.Is(err, ErrBudgetExhausted) {
+			t.Errorf("Expected ErrBudgetExhausted, got %v", err)
+		}
+		if second.State != StatePriced {
+			t.Errorf("Expected the order to stay priced, got %s", second.State)
+		}
+	})
+	t.Run("Concurrent reservations never overspend", func(t *testing.T) {
+		ledger := NewMemoryBudgetLedger(Budget{PromID: "HOFF", Limit: 1000})
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				ledger.Reserve(fmt.Sprint(i), "HOFF", 150)
+			}(i)
+		}
+		wg.Wait()
+		if report := ledger.Report(); report[0].Used != 900 || report[0].Orders != 6 {
+			t.Errorf("Expected 900 used by 6 orders, got %+v", report[0])
+		}
+	})
+}
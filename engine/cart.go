@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Cart keeps an Order priced while items are scanned at the POS. The caller doesn't need to call CalcTotal or CalcDiscount,
+// every operation updates Order.Total with the difference of the changed line and only recomputes the promotions that the line can affect.
+// The result of every promotion is cached in results which has the same index as Order.Promotions, and the results are
+// settled with the Pricing policy the same way Price settles them, so budgets, adjustments and rounding apply to the cart too
+type Cart struct {
+	Order   Order
+	Pricing PricingPolicy
+	results []PromotionResult
+	history []cartChange
+}
+
+// cartChange records a single line change so it can be undone. before is nil when the line was added and after is nil when it was removed
+type cartChange struct {
+	index  int
+	before *Item
+	after  *Item
+}
+
+var ErrNothingToUndo = errors.New("cart: nothing to undo")
+
+// NewCart creates an empty cart with the promotions that are applied to the order. The order is created now,
+// so promotions with a validity window, birthday or win-back condition are checked against the time of the sale
+func NewCart(id string, promotions []Promotion) *Cart {
+	cart := &Cart{
+		Order:   Order{ID: id, CreatedAt: time.Now(), Promotions: promotions},
+		results: make([]PromotionResult, len(promotions)),
+	}
+	for i, prom := range promotions {
+		cart.results[i] = PromotionResult{PromID: prom.PromID, PromName: prom.PromName}
+	}
+	return cart
+}
+
+// AddItem adds a new line to the cart. If a line of the same SKU, price and unit is already in the cart, the amount or the weighed
+// quantity is added to that line instead, the same lines Normalize merges. A scan of the SKU at another price or unit is its own line
+func (cart *Cart) AddItem(item Item) error {
+	if cart.Order.Frozen() {
+		return ErrOrderFrozen
+	}
+	if errs := validateItem("Item", item); len(errs) > 0 {
+		return errs
+	}
+	if index := cart.findLine(item); index >= 0 {
+		before := cart.Order.Items[index]
+		after := before
+		after.Amount += item.Amount
+		after.Quantity += item.Quantity
+		after.ValidSelectedItem = after.ValidSelectedItem || item.ValidSelectedItem
+		after.ValidFreeItem = after.ValidFreeItem || item.ValidFreeItem
+		after.ValidFiftyOff = after.ValidFiftyOff || item.ValidFiftyOff
+		after.Chosen = after.Chosen || item.Chosen
+		return cart.apply(cartChange{index: index, before: &before, after: &after}, true)
+	}
+	return cart.apply(cartChange{index: len(cart.Order.Items), after: &item}, true)
+}
+
+// RemoveItem removes the line from the cart. Lines are addressed by their index in Order.Items since the same SKU
+// can be on several lines at different prices or units
+func (cart *Cart) RemoveItem(line int) error {
+	if cart.Order.Frozen() {
+		return ErrOrderFrozen
+	}
+	if err := cart.checkLine(line); err != nil {
+		return err
+	}
+	before := cart.Order.Items[line]
+	return cart.apply(cartChange{index: line, before: &before}, true)
+}
+
+// SetQuantity changes the amount of the line. Setting the amount to 0 removes the line. Use SetWeight for weighted items
+func (cart *Cart) SetQuantity(line int, amount int64) error {
+	if amount < 0 {
+		return fmt.Errorf("cart: amount of line %d can't be negative, got %d", line, amount)
+	}
+	return cart.setLine(line, false, func(item *Item) bool {
+		item.Amount = amount
+		return amount == 0
+	})
+}
+
+// SetWeight changes the quantity of a weighted line, like after the item is weighed again. Setting it to 0 removes the line
+func (cart *Cart) SetWeight(line int, quantity float64) error {
+	if !(quantity >= 0) {
+		return fmt.Errorf("cart: quantity of line %d can't be negative, got %g", line, quantity)
+	}
+	return cart.setLine(line, true, func(item *Item) bool {
+		item.Quantity = quantity
+		return quantity == 0
+	})
+}
+
+// setLine changes the line with set, which reports if the line should be removed instead
+func (cart *Cart) setLine(line int, weighted bool, set func(item *Item) bool) error {
+	if cart.Order.Frozen() {
+		return ErrOrderFrozen
+	}
+	if err := cart.checkLine(line); err != nil {
+		return err
+	}
+	before := cart.Order.Items[line]
+	if before.Weighted() != weighted {
+		if weighted {
+			return fmt.Errorf("cart: %s on line %d is sold per piece, use SetQuantity", before.SKU, line)
+		}
+		return fmt.Errorf("cart: %s on line %d is sold by %s, use SetWeight", before.SKU, line, before.Unit)
+	}
+	after := before
+	if set(&after) {
+		return cart.RemoveItem(line)
+	}
+	return cart.apply(cartChange{index: line, before: &before, after: &after}, true)
+}
+
+func (cart *Cart) checkLine(line int) error {
+	if line < 0 || line >= len(cart.Order.Items) {
+		return fmt.Errorf("cart: no line %d in the cart", line)
+	}
+	return nil
+}
+
+// Undo reverts the last AddItem, RemoveItem or SetQuantity. The reverted change is not recorded so it can't be redone
+func (cart *Cart) Undo() error {
+	if cart.Order.Frozen() {
+		return ErrOrderFrozen
+	}
+	if len(cart.history) == 0 {
+		return ErrNothingToUndo
+	}
+	last := cart.history[len(cart.history)-1]
+	cart.history = cart.history[:len(cart.history)-1]
+	if err := cart.apply(cartChange{index: last.index, before: last.after, after: last.before}, false); err != nil {
+		cart.history = append(cart.history, last)
+		return err
+	}
+	return nil
+}
+
+// Results returns the cached discount of every promotion in the cart, before the budgets of the Pricing policy
+func (cart *Cart) Results() []PromotionResult {
+	return append([]PromotionResult(nil), cart.results...)
+}
+
+// findLine returns the line the item is merged into, -1 when it needs a line of its own
+func (cart *Cart) findLine(item Item) int {
+	for i, line := range cart.Order.Items {
+		if line.SKU == item.SKU && line.Price == item.Price && line.Unit == item.Unit {
+			return i
+		}
+	}
+	return -1
+}
+
+// apply changes the line and settles the pricing of the cart. A change that the Pricing policy rejects, like removing
+// the line of an adjustment, is reverted and not recorded
+func (cart *Cart) apply(change cartChange, record bool) error {
+	cart.change(change)
+	if err := cart.settle(); err != nil {
+		cart.change(cartChange{index: change.index, before: change.after, after: change.before})
+		cart.settle()
+		return err
+	}
+	if record {
+		cart.history = append(cart.history, change)
+	}
+	return nil
+}
+
+func (cart *Cart) settle() error {
+	if err := cart.Pricing.validate(cart.Order); err != nil {
+		return err
+	}
+	_, err := cart.Pricing.settle(&cart.Order, cart.Results())
+	return err
+}
+
+// change changes the line, adjusts the total by the difference of the line and recomputes the affected promotions
+// Removed lines are taken out of the slice in place so the scanned order of the remaining lines is kept
+func (cart *Cart) change(change cartChange) {
+	items := cart.Order.Items
+	switch {
+	case change.before == nil:
+		items = append(items, Item{})
+		copy(items[change.index+1:], items[change.index:])
+		items[change.index] = *change.after
+	case change.after == nil:
+		items = append(items[:change.index], items[change.index+1:]...)
+	default:
+		items[change.index] = *change.after
+	}
+	cart.Order.Items = items
+	cart.Order.Total += lineTotal(change.after) - lineTotal(change.before)
+	if len(items) == 0 {
+		// Avoid carrying rounding errors of the float into an empty cart
+		cart.Order.Total = 0
+	}
+
+	linesChanged := change.before == nil || change.after == nil
+	history := &customerHistory{}
+	for i, prom := range cart.Order.Promotions {
+		if len(items) == 0 || prom.affectedBy(change.before, linesChanged) || prom.affectedBy(change.after, linesChanged) {
+			cart.results[i] = prom.result(cart.Order, history)
+		}
+	}
+}
+
+func lineTotal(item *Item) float64 {
+	if item == nil {
+		return 0
+	}
+	return item.Price * item.Qty()
+}
+
+// affectedBy reports if a change to the item can change the discount of the promotion.
+// Promotions on the order total are always affected, the rest only look at the lines that meet their conditions.
+// linesChanged is for promotions that need a minimum number of lines in the order
+func (prom Promotion) affectedBy(item *Item, linesChanged bool) bool {
+	if item == nil {
+		return false
+	}
+	switch prom.PromID {
+	case "B2G1":
+		return item.Amount >= 3
+	case "B1N1":
+		return item.Amount > 1
+	case "B2I1":
+		return linesChanged || item.ValidSelectedItem || item.ValidFreeItem
+	case "B1NH":
+		return linesChanged || item.ValidFiftyOff
+	}
+	return true
+}
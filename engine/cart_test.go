@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+// The cart should always give the same total and discount as calling CalcTotal and CalcDiscount on the same items
+func priceLikeOrder(cart *Cart) Order {
+	order := Order{ID: cart.Order.ID, Items: append([]Item(nil), cart.Order.Items...), Promotions: cart.Order.Promotions}
+	order.CalcTotal()
+	order.CalcDiscount()
+	return order
+}
+
+func TestCart(t *testing.T) {
+	promotions := []Promotion{
+		{PromName: "Buy2Get1Free", PromID: "B2G1"},
+		{PromName: "100 Baht off over 1000", PromID: "D100"},
+		{PromName: "Buy A,B get C free", PromID: "B2I1"},
+	}
+	t.Run("Add, update and remove items", func(t *testing.T) {
+		cart := NewCart("1", promotions)
+		cart.AddItem(Item{SKU: "A", Price: 250, Amount: 2, ValidSelectedItem: true})
+		// Only 500 Baht so no promotion is applicable yet
+		if cart.Order.Total != 500 || cart.Order.Discount != 0 {
+			t.Errorf("Expected total 500 and discount 0, got %f and %f", cart.Order.Total, cart.Order.Discount)
+		}
+		cart.SetQuantity(0, 3)
+		// 3 of SKU A makes Buy2Get1Free applicable with 250 Baht discount
+		if cart.Order.Total != 750 || cart.Order.Discount != 250 {
+			t.Errorf("Expected total 750 and discount 250, got %f and %f", cart.Order.Total, cart.Order.Discount)
+		}
+		cart.AddItem(Item{SKU: "B", Price: 100, Amount: 1, ValidSelectedItem: true})
+		cart.AddItem(Item{SKU: "C", Price: 400, Amount: 1, ValidFreeItem: true})
+		// SKU A and SKU B are selected so SKU C is free with a discount of 400 Baht
+		if cart.Order.Total != 1250 || cart.Order.Discount != 400 {
+			t.Errorf("Expected total 1250 and discount 400, got %f and %f", cart.Order.Total, cart.Order.Discount)
+		}
+		cart.RemoveItem(1)
+		// Without SKU B, Buy2Get1Free is the highest discount again
+		if cart.Order.Total != 1150 || cart.Order.Discount != 250 {
+			t.Errorf("Expected total 1150 and discount 250, got %f and %f", cart.Order.Total, cart.Order.Discount)
+		}
+		order := priceLikeOrder(cart)
+		if cart.Order.Total != order.Total || cart.Order.Discount != order.Discount {
+			t.Errorf("Expected cart to match order %f/%f, got %f/%f", order.Total, order.Discount, cart.Order.Total, cart.Order.Discount)
+		}
+	})
+	t.Run("Adding the same SKU increases the amount", func(t *testing.T) {
+		cart := NewCart("2", promotions)
+		cart.AddItem(Item{SKU: "A", Price: 10, Amount: 1})
+		cart.AddItem(Item{SKU: "A", Price: 10, Amount: 2})
+		if len(cart.Order.Items) != 1 || cart.Order.Items[0].Amount != 3 {
+			t.Errorf("Expected one line with 3 of SKU A, got %v", cart.Order.Items)
+		}
+		if cart.Order.Discount != 10 {
+			t.Errorf("Expected discount to be 10, got %f", cart.Order.Discount)
+		}
+	})
+	t.Run("Same SKU at another price or unit is a new line", func(t *testing.T) {
+		cart := NewCart("2b", promotions)
+		cart.AddItem(Item{SKU: "A", Price: 100, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false})
+		cart.AddItem(Item{SKU: "A", Price: 50, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false})
+		if len(cart.Order.Items) != 2 || cart.Order.Total != 150 {
+			t.Errorf("Expected two lines with a total of 150, got %v and %f", cart.Order.Items, cart.Order.Total)
+		}
+		cart.AddItem(Item{SKU: "A", Price: 100, Unit: UnitKg, Quantity: 0.5, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false})
+		if len(cart.Order.Items) != 3 || cart.Order.Total != 200 {
+			t.Errorf("Expected the weighted scan on its own line with a total of 200, got %v and %f", cart.Order.Items, cart.Order.Total)
+		}
+		if err := cart.Order.Validate(); err != nil {
+			t.Errorf("Expected the cart to stay valid, got %v", err)
+		}
+		cart.AddItem(Item{SKU: "A", Price: 50, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: true})
+		if cart.Order.Items[1].Amount != 2 || !cart.Order.Items[1].ValidFiftyOff {
+			t.Errorf("Expected the scan merged into the line at 50 with its flag, got %v", cart.Order.Items[1])
+		}
+	})
+	t.Run("Lines of the same SKU are changed by index", func(t *testing.T) {
+		cart := NewCart("2c", promotions)
+		cart.AddItem(Item{SKU: "A", Price: 100, Amount: 1})
+		cart.AddItem(Item{SKU: "A", Price: 50, Amount: 1})
+		// The second line is the one at 50, not the first line of SKU A
+		if err := cart.SetQuantity(1, 3); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if cart.Order.Items[0].Amount != 1 || cart.Order.Items[1].Amount != 3 || cart.Order.Total != 250 {
+			t.Errorf("Expected 1 at 100 and 3 at 50 with a total of 250, got %v and %f", cart.Order.Items, cart.Order.Total)
+		}
+		if err := cart.RemoveItem(1); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(cart.Order.Items) != 1 || cart.Order.Items[0].Price != 100 {
+			t.Errorf("Expected only the line at 100, got %v", cart.Order.Items)
+		}
+	})
+	t.Run("Promotion within its validity window", func(t *testing.T) {
+		now := time.Now()
+		cart := NewCart("2d", []Promotion{{PromName: "50% Off", PromID: "HOFF", ValidFrom: now.Add(-time.Hour), ValidUntil: now.Add(time.Hour)}})
+		if cart.Order.CreatedAt.IsZero() {
+			t.Errorf("Expected the cart to be created now")
+		}
+		cart.AddItem(Item{SKU: "A", Price: 100, Amount: 1, ValidFiftyOff: true})
+		if cart.Order.Discount != 50 {
+			t.Errorf("Expected discount to be 50, got %f", cart.Order.Discount)
+		}
+	})
+	t.Run("Undo", func(t *testing.T) {
+		cart := NewCart("3", promotions)
+		cart.AddItem(Item{SKU: "A", Price: 600, Amount: 1})
+		cart.AddItem(Item{SKU: "B", Price: 500, Amount: 1})
+		cart.RemoveItem(0)
+		cart.SetQuantity(0, 3)
+		// Undo the quantity change and the removal, SKU A should be back in its scanned position
+		cart.Undo()
+		cart.Undo()
+		if len(cart.Order.Items) != 2 || cart.Order.Items[0].SKU != "A" || cart.Order.Items[1].Amount != 1 {
+			t.Errorf("Expected SKU A and 1 of SKU B, got %v", cart.Order.Items)
+		}
+		// 1100 Baht total makes the 100 Baht discount applicable
+		if cart.Order.Total != 1100 || cart.Order.Discount != 100 {
+			t.Errorf("Expected total 1100 and discount 100, got %f and %f", cart.Order.Total, cart.Order.Discount)
+		}
+		cart.Undo()
+		cart.Undo()
+		if len(cart.Order.Items) != 0 || cart.Order.Total != 0 || cart.Order.Discount != 0 {
+			t.Errorf("Expected empty cart, got %v with total %f and discount %f", cart.Order.Items, cart.Order.Total, cart.Order.Discount)
+		}
+		if err := cart.Undo(); err != ErrNothingToUndo {
+			t.Errorf("Expected ErrNothingToUndo, got %v", err)
+		}
+	})
+	t.Run("Priced with the policy of the cart", func(t *testing.T) {
+		cart := NewCart("5", promotions)
+		cart.Pricing = PricingPolicy{Budgets: NewMemoryBudgetLedger(Budget{PromID: "D100", Limit: 40, Mode: BudgetPartial}),
+			Adjustments: AdjustmentPolicy{Limits: adjustmentLimits, Staff: adjustmentStaff, Interaction: Stack}}
+		cart.AddItem(Item{SKU: "A", Price: 600, Amount: 1})
+		cart.AddItem(Item{SKU: "B", Price: 500, Amount: 1})
+		// D100 is limited to the 40 left of its budget
+		if cart.Order.Discount != 40 || cart.Order.Applied.PromID != "D100" {
+			t.Errorf("Expected 40 from D100, got %f and %+v", cart.Order.Discount, cart.Order.Applied)
+		}
+		cart.Order.Adjustments = []Adjustment{{Kind: LineDiscount, Line: 1, Amount: 20, Reason: "DAMAGED", StaffID: "S1"}}
+		// Removing the line of the adjustment is rejected and the line stays in the cart
+		if err := cart.RemoveItem(1); err == nil || len(cart.Order.Items) != 2 || cart.Order.Total != 1100 {
+			t.Errorf("Expected the removal to be rejected, got %v with %v", err, cart.Order.Items)
+		}
+		cart.AddItem(Item{SKU: "C", Price: 10, Amount: 1})
+		if cart.Order.Discount != 60 {
+			t.Errorf("Expected 40 from D100 and 20 from the adjustment, got %f", cart.Order.Discount)
+		}
+	})
+	t.Run("Invalid operations", func(t *testing.T) {
+		cart := NewCart("4", promotions)
+		if err := cart.AddItem(Item{SKU: "A", Price: 10, Amount: 0}); err == nil {
+			t.Errorf("Expected error for 0 amount")
+		}
+		if err := cart.RemoveItem(0); err == nil {
+			t.Errorf("Expected error for removing a line that isn't in the cart")
+		}
+		if err := cart.SetQuantity(0, -1); err == nil {
+			t.Errorf("Expected error for negative amount")
+		}
+		// Failed operations are not recorded in the history
+		if err := cart.Undo(); err != ErrNothingToUndo {
+			t.Errorf("Expected ErrNothingToUndo, got %v", err)
+		}
+	})
+}
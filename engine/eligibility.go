@@ -0,0 +1,38 @@
+package engine
+
+import (
+	"time"
+)
+
+// Eligible reports if the order can get the promotion at all, before its discount is calculated.
+// The reason is shown in the breakdown of the pricing result when the order isn't eligible.
+// The validity window is checked against CreatedAt and never the current time, so the same order always prices the same.
+// Orders without CreatedAt don't get promotions with a validity window
+func (prom Promotion) Eligible(order Order) (bool, string) {
+	return prom.eligible(order, &customerHistory{})
+}
+
+// eligible looks up the history of the customer through the cache, so it is looked up once for all the promotions of an order
+func (prom Promotion) eligible(order Order, cache *customerHistory) (bool, string) {
+	if !prom.ValidFrom.IsZero() || !prom.ValidUntil.IsZero() {
+		if order.CreatedAt.IsZero() {
+			return false, "only within its validity window, the order has no CreatedAt"
+		}
+		if !prom.ValidFrom.IsZero() && order.CreatedAt.Before(prom.ValidFrom) {
+			return false, "not valid until " + prom.ValidFrom.Format(time.RFC3339)
+		}
+		if !prom.ValidUntil.IsZero() && !order.CreatedAt.Before(prom.ValidUntil) {
+			return false, "expired at " + prom.ValidUntil.Format(time.RFC3339)
+		}
+	}
+	if reason := prom.Scope.inScope(order.Context); reason != "" {
+		return false, reason
+	}
+	if reason := prom.Customer.customerEligible(order, cache); reason != "" {
+		return false, reason
+	}
+	if reason := prom.Payment.paymentEligible(order.Payment); reason != "" {
+		return false, reason
+	}
+	return true, ""
+}
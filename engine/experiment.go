@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Experiment splits customers between variants of a promotion configuration, for example INCD capped at 1000 against INCD capped at 500.
+// The assignment is a hash of the salt and the customer ID so a customer always sees the same variant while the experiment runs.
+// Changing the salt reshuffles the customers for a new experiment. Orders without a customer are assigned by their order ID.
+type Experiment struct {
+	Name     string
+	Salt     string
+	Variants []Variant
+	Pricing  PricingPolicy // Adjustments, budgets and rounding every variant is priced with
+}
+
+// Variant is one arm of the experiment. Weight is the share of customers relative to the other variants
+type Variant struct {
+	Name       string
+	Weight     int
+	Promotions []Promotion
+}
+
+func (exp Experiment) Validate() error {
+	if len(exp.Variants) == 0 {
+		return fmt.Errorf("experiment %s: no variants", exp.Name)
+	}
+	for _, variant := range exp.Variants {
+		if variant.Weight <= 0 {
+			return fmt.Errorf("experiment %s: weight of variant %s must be greater than 0", exp.Name, variant.Name)
+		}
+	}
+	return nil
+}
+
+// Assign returns the variant of the order. The same customer or order always gets the same variant
+func (exp Experiment) Assign(order Order) (Variant, error) {
+	if err := exp.Validate(); err != nil {
+		return Variant{}, err
+	}
+	unit := order.CustomerID
+	if unit == "" {
+		unit = order.ID
+	}
+	if unit == "" {
+		return Variant{}, errors.New("experiment: order has no customer or order ID to assign")
+	}
+	total := 0
+	for _, variant := range exp.Variants {
+		total += variant.Weight
+	}
+	sum := sha256.Sum256([]byte(exp.Salt + ":" + unit))
+	bucket := int(binary.BigEndian.Uint64(sum[:8]) % uint64(total))
+	for _, variant := range exp.Variants {
+		if bucket < variant.Weight {
+			return variant, nil
+		}
+		bucket -= variant.Weight
+	}
+	// Unreachable since bucket is always smaller than the total weight
+	return exp.Variants[len(exp.Variants)-1], nil
+}
+
+// Price prices the order with the promotions of its variant and the Pricing policy and records the variant on the result
+func (exp Experiment) Price(order *Order) (PricingResult, error) {
+	variant, err := exp.Assign(*order)
+	if err != nil {
+		return PricingResult{}, err
+	}
+	order.Promotions = variant.Promotions
+	result, err := exp.Pricing.Price(order)
+	if err != nil {
+		return result, err
+	}
+	result.Experiment = exp.Name
+	result.Variant = variant.Name
+	return result, nil
+}
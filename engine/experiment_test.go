@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+)
+
+func incdExperiment() Experiment {
+	return Experiment{
+		Name: "INCD cap",
+		Salt: "2026-10",
+		Variants: []Variant{
+			{Name: "control", Weight: 1, Promotions: []Promotion{{PromName: "1 15%, 2 20%, 3 30%", PromID: "INCD"}}},
+			{Name: "cap500", Weight: 1, Promotions: []Promotion{{PromName: "1 15%, 2 20%, 3 30%", PromID: "INCD", Cap: 500}}},
+		},
+	}
+}
+
+func TestExperiment(t *testing.T) {
+	t.Run("Same customer always gets the same variant", func(t *testing.T) {
+		exp := incdExperiment()
+		first, _ := exp.Assign(Order{ID: "1", CustomerID: "C42"})
+		for i := 0; i < 20; i++ {
+			variant, _ := exp.Assign(Order{ID: fmt.Sprint(i), CustomerID: "C42"})
+			if variant.Name != first.Name {
+				t.Fatalf("Expected variant %s for every order of the customer, got %s", first.Name, variant.Name)
+			}
+		}
+	})
+	t.Run("Customers are split by weight", func(t *testing.T) {
+		exp := incdExperiment()
+		exp.Variants[1].Weight = 3
+		counts := make(map[string]int)
+		for i := 0; i < 4000; i++ {
+			variant, _ := exp.Assign(Order{CustomerID: fmt.Sprint("C", i)})
+			counts[variant.Name]++
+		}
+		// 1:3 split should give roughly 1000 customers in control
+		if counts["control"] < 900 || counts["control"] > 1100 {
+			t.Errorf("Expected about 1000 customers in control, got %v", counts)
+		}
+	})
+	t.Run("Variant is recorded on the result", func(t *testing.T) {
+		exp := incdExperiment()
+		order := Order{
+			ID: "1",
+			Items: []Item{
+				{SKU: "A", Price: 5000, Amount: 3, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+			},
+		}
+		// Find a customer that is in the cap500 variant
+		for i := 0; ; i++ {
+			order.CustomerID = fmt.Sprint("C", i)
+			variant, _ := exp.Assign(order)
+			if variant.Name == "cap500" {
+				break
+			}
+		}
+		result, err := exp.Price(&order)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		// 30% of 15000 is 4500 but the variant caps it at 500
+		if result.Variant != "cap500" || result.Experiment != "INCD cap" || result.Discount != 500 {
+			t.Errorf("Expected cap500 with 500 discount, got %s with %f", result.Variant, result.Discount)
+		}
+	})
+	t.Run("Invalid experiment", func(t *testing.T) {
+		exp := incdExperiment()
+		exp.Variants[0].Weight = 0
+		if _, err := exp.Assign(Order{ID: "1"}); err == nil {
+			t.Errorf("Expected error for variant with 0 weight")
+		}
+		if _, err := incdExperiment().Assign(Order{}); err == nil {
+			t.Errorf("Expected error for order without customer or order ID")
+		}
+	})
+}
+
+func TestPromotionCap(t *testing.T) {
+	order := Order{
+		ID: "1",
+		Items: []Item{
+			{SKU: "A", Price: 5000, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		},
+		Promotions: []Promotion{
+			{PromName: "50% Off", PromID: "HOFF", Cap: 300},
+			{PromName: "1 15%, 2 20%, 3 30%", PromID: "INCD", Cap: 2000},
+		},
+	}
+	result, _ := order.Price()
+	// HOFF is capped at 300 and INCD at 750 since the cap of 2000 replaces the default 1000
+	if result.Breakdown[0].Discount != 300 || result.Breakdown[1].Discount != 750 || result.Applied.PromID != "INCD" {
+		t.Errorf("Expected 300 and 750 with INCD applied, got %v", result)
+	}
+}
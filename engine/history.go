@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// CustomerHistory is what promotions know about the past orders of a customer. The order being priced is not part of it
+type CustomerHistory struct {
+	CustomerID string
+	Orders     int       // Number of paid orders
+	LastOrder  time.Time // When the last paid order was placed, zero when there is none
+	Birthday   time.Time // Only the month and day are used, zero when unknown
+	Household  string    // Customers at the same address share a household, empty when unknown
+}
+
+// HistoryProvider looks up the history of a customer. Customers without any orders are not an error,
+// their history only has the CustomerID
+type HistoryProvider interface {
+	History(customerID string) (CustomerHistory, error)
+}
+
+// CustomerCondition limits a promotion to customers with a certain history, like the first order of a customer,
+// the month of their birthday or customers who haven't ordered in a while. The zero CustomerCondition allows every customer
+type CustomerCondition struct {
+	FirstOrder    bool
+	BirthdayMonth bool
+	InactiveDays  int  // Win-back: no order in at least this many days before the order
+	Referred      bool // Only orders with a referral code accepted for them by a ReferralProgram, checked in Order.Referrals
+}
+
+func (cond CustomerCondition) empty() bool {
+	return cond == CustomerCondition{}
+}
+
+// customerHistory is the history of the customer of an order, looked up at most once and shared by every promotion of a pricing
+type customerHistory struct {
+	looked  bool
+	history CustomerHistory
+	reason  string // Why the history isn't available, empty when it was found
+}
+
+func (cache *customerHistory) lookup(order Order) (CustomerHistory, string) {
+	if cache.looked {
+		return cache.history, cache.reason
+	}
+	cache.looked = true
+	switch {
+	case order.CustomerID == "":
+		cache.reason = "only for known customers, the order has no customer"
+	case order.History == nil:
+		cache.reason = "customer history is not available"
+	default:
+		history, err := order.History.History(order.CustomerID)
+		if err != nil {
+			cache.reason = "customer history is not available: " + err.Error()
+		}
+		cache.history = history
+	}
+	return cache.history, cache.reason
+}
+
+// customerEligible returns why the customer of the order doesn't meet the condition, or an empty string when they do.
+// The history is only looked up for conditions that need it
+func (cond CustomerCondition) customerEligible(order Order, cache *customerHistory) string {
+	if cond.empty() {
+		return ""
+	}
+	if cond.Referred {
+		if reason := order.referralEligible(); reason != "" {
+			return reason
+		}
+	}
+	if cond == (CustomerCondition{Referred: true}) {
+		return ""
+	}
+	history, reason := cache.lookup(order)
+	if reason != "" {
+		return reason
+	}
+	now := order.CreatedAt
+	if cond.FirstOrder && history.Orders > 0 {
+		return fmt.Sprintf("only for the first order, customer has %d orders", history.Orders)
+	}
+	if cond.BirthdayMonth {
+		if history.Birthday.IsZero() {
+			return "only in the birthday month, the birthday of the customer is unknown"
+		}
+		if now.IsZero() {
+			return "only in the birthday month, the order has no CreatedAt"
+		}
+		if history.Birthday.Month() != now.Month() {
+			return fmt.Sprintf("only in the birthday month, which is %s", history.Birthday.Month())
+		}
+	}
+	if cond.InactiveDays > 0 {
+		if history.LastOrder.IsZero() {
+			return "only for returning customers, customer has never ordered"
+		}
+		if now.IsZero() {
+			return "only for returning customers, the order has no CreatedAt"
+		}
+		if days := int(now.Sub(history.LastOrder).Hours() / 24); days < cond.InactiveDays {
+			return fmt.Sprintf("only for customers without an order in %d days, last order was %d days ago", cond.InactiveDays, days)
+		}
+	}
+	return ""
+}
+
+// MemoryHistoryProvider keeps the history of customers in memory
+type MemoryHistoryProvider struct {
+	mu        sync.RWMutex
+	customers map[string]CustomerHistory
+}
+
+func NewMemoryHistoryProvider(histories ...CustomerHistory) *MemoryHistoryProvider {
+	provider := &MemoryHistoryProvider{customers: make(map[string]CustomerHistory)}
+	for _, history := range histories {
+		provider.customers[history.CustomerID] = history
+	}
+	return provider
+}
+
+func (provider *MemoryHistoryProvider) History(customerID string) (CustomerHistory, error) {
+	provider.mu.RLock()
+	defer provider.mu.RUnlock()
+	if history, ok := provider.customers[customerID]; ok {
+		return history, nil
+	}
+	return CustomerHistory{CustomerID: customerID}, nil
+}
+
+// Set replaces the history of the customer
+func (provider *MemoryHistoryProvider) Set(history CustomerHistory) {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	provider.customers[history.CustomerID] = history
+}
+
+// RecordOrder adds a paid order to the history of its customer
+func (provider *MemoryHistoryProvider) RecordOrder(order Order) {
+	if order.CustomerID == "" {
+		return
+	}
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	history := provider.customers[order.CustomerID]
+	history.CustomerID = order.CustomerID
+	history.Orders++
+	if order.CreatedAt.After(history.LastOrder) {
+		history.LastOrder = order.CreatedAt
+	}
+	provider.customers[order.CustomerID] = history
+}
+
+// LoadHistoryFile reads a JSON array of CustomerHistory into a MemoryHistoryProvider
+func LoadHistoryFile(path string) (*MemoryHistoryProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var histories []CustomerHistory
+	if err := json.Unmarshal(data, &histories); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, history := range histories {
+		if history.CustomerID == "" {
+			return nil, fmt.Errorf("%s: customer %d has no CustomerID", path, i)
+		}
+	}
+	return NewMemoryHistoryProvider(histories...), nil
+}
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCustomerHistory(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	history := NewMemoryHistoryProvider(
+		CustomerHistory{CustomerID: "regular", Orders: 12, LastOrder: now.AddDate(0, 0, -3), Birthday: time.Date(1990, 1, 15, 0, 0, 0, 0, time.UTC)},
+		CustomerHistory{CustomerID: "lapsed", Orders: 2, LastOrder: now.AddDate(0, 0, -120), Birthday: time.Date(1985, 5, 30, 0, 0, 0, 0, time.UTC)},
+	)
+	promotions := []Promotion{
+		{PromName: "First order 20% off", PromID: "PCTO", Percent: 20, Customer: CustomerCondition{FirstOrder: true}},
+		{PromName: "Birthday month 200 Baht off", PromID: "BAHT", Value: 200, Customer: CustomerCondition{BirthdayMonth: true}},
+		{PromName: "We miss you 10% off", PromID: "PCTO", Percent: 10, Customer: CustomerCondition{InactiveDays: 90}},
+	}
+	cases := []struct {
+		customer string
+		expected []float64
+	}{
+		{"new", []float64{180, 0, 0}},
+		{"regular", []float64{0, 0, 0}},
+		{"lapsed", []float64{0, 200, 90}},
+		{"", []float64{0, 0, 0}},
+	}
+	for _, c := range cases {
+		order := Order{ID: "1", CustomerID: c.customer, CreatedAt: now, History: history, Promotions: promotions, Items: []Item{
+			{SKU: "A", Price: 450, Amount: 2, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		}}
+		order.CalcTotal()
+		results := order.Evaluate()
+		for i, discount := range c.expected {
+			if results[i].Discount != discount {
+				t.Errorf("%q: expected %s to be %f, got %f (%s)", c.customer, results[i].PromName, discount, results[i].Discount, results[i].Reason)
+			}
+			if discount == 0 && results[i].Reason == "" {
+				t.Errorf("%q: expected a reason for %s", c.customer, results[i].PromName)
+			}
+		}
+	}
+	t.Run("Without a history provider", func(t *testing.T) {
+		order := Order{ID: "1", CustomerID: "new", Promotions: promotions[:1], Items: []Item{
+			{SKU: "A", Price: 450, Amount: 2, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		}}
+		order.CalcTotal()
+		order.CalcDiscount()
+		if order.Discount != 0 {
+			t.Errorf("Expected discount to be 0, got %f", order.Discount)
+		}
+	})
+	t.Run("History is looked up once per pricing", func(t *testing.T) {
+		counting := &countingHistoryProvider{HistoryProvider: history}
+		order := Order{ID: "1", CustomerID: "lapsed", CreatedAt: now, History: counting, Promotions: promotions, Items: []Item{
+			{SKU: "A", Price: 450, Amount: 2, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		}}
+		order.Price()
+		if counting.calls != 1 {
+			t.Errorf("Expected 1 lookup for 3 promotions, got %d", counting.calls)
+		}
+	})
+	t.Run("Paid orders are recorded", func(t *testing.T) {
+		history.RecordOrder(Order{ID: "2", CustomerID: "new", CreatedAt: now})
+		if got, _ := history.History("new"); got.Orders != 1 || !got.LastOrder.Equal(now) {
+			t.Errorf("Expected 1 order at %s, got %+v", now, got)
+		}
+	})
+	t.Run("History file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "history.json")
+		os.WriteFile(path, []byte(`[{"CustomerID": "C1", "Orders": 3, "Birthday": "1990-05-02T00:00:00Z"}]`), 0o644)
+		provider, err := LoadHistoryFile(path)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got, _ := provider.History("C1"); got.Orders != 3 || got.Birthday.Month() != time.May {
+			t.Errorf("Expected 3 orders and a birthday in May, got %+v", got)
+		}
+	})
+}
+
+type countingHistoryProvider struct {
+	HistoryProvider
+	calls int
+}
+
+func (provider *countingHistoryProvider) History(customerID string) (CustomerHistory, error) {
+	provider.calls++
+	return provider.HistoryProvider.History(customerID)
+}
+
+func TestBahtOff(t *testing.T) {
+	order := Order{ID: "1", Items: []Item{
+		{SKU: "A", Price: 150, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+	}, Promotions: []Promotion{{PromName: "200 Baht off", PromID: "BAHT", Value: 200}}}
+	order.CalcTotal()
+	order.CalcDiscount()
+	// The discount can't be more than the total
+	if order.Discount != 150 {
+		t.Errorf("Expected discount to be 150, got %f", order.Discount)
+	}
+}
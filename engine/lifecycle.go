@@ -0,0 +1,242 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+type OrderState string
+
+const (
+	StateDraft             OrderState = "draft"
+	StatePriced            OrderState = "priced"
+	StateLocked            OrderState = "locked"
+	StatePaid              OrderState = "paid"
+	StatePartiallyRefunded OrderState = "partially_refunded"
+	StateRefunded          OrderState = "refunded"
+	StateCancelled         OrderState = "cancelled"
+)
+
+// transitions lists the states an order can move to from each state. Refunded and cancelled orders are final.
+// A priced order can go back to draft when items are changed, a locked order can only be paid or cancelled.
+var transitions = map[OrderState][]OrderState{
+	StateDraft:             {StatePriced, StateCancelled},
+	StatePriced:            {StatePriced, StateDraft, StateLocked, StateCancelled},
+	StateLocked:            {StatePaid, StateCancelled},
+	StatePaid:              {StatePartiallyRefunded, StateRefunded},
+	StatePartiallyRefunded: {StatePartiallyRefunded, StateRefunded},
+}
+
+var (
+	ErrInvalidTransition = errors.New("invalid order state transition")
+	ErrOrderFrozen       = errors.New("order is locked and can't be changed")
+)
+
+// CurrentState returns the state of the order, orders without a state are drafts
+func (order *Order) CurrentState() OrderState {
+	if order.State == "" {
+		return StateDraft
+	}
+	return order.State
+}
+
+// Frozen reports if the pricing of the order can no longer change, which is from the moment it is locked
+func (order *Order) Frozen() bool {
+	switch order.CurrentState() {
+	case StateDraft, StatePriced:
+		return false
+	}
+	return true
+}
+
+func CanTransition(from, to OrderState) bool {
+	for _, state := range transitions[from] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+// OrderEvent is emitted for every transition of an order
+type OrderEvent struct {
+	OrderID string
+	From    OrderState
+	To      OrderState
+	Time    time.Time
+}
+
+// VoucherLedger tracks the redemptions of voucher PromIDs. The applied promotion is redeemed when the order is locked
+// and every redemption of the order is released when it is cancelled
+type VoucherLedger interface {
+	Redeem(orderID, promID string) error
+	Release(orderID string) error
+}
+
+// Lifecycle moves orders between states and notifies subscribers of every transition.
+// Vouchers is optional, without it no redemptions are recorded. Orders are priced with the Pricing policy,
+// which has the adjustment limits and the budgets of the promotions
+type Lifecycle struct {
+	Vouchers VoucherLedger
+	Pricing  PricingPolicy
+	Now      func() time.Time
+
+	mu          sync.RWMutex
+	subscribers []func(OrderEvent)
+}
+
+func NewLifecycle(vouchers VoucherLedger) *Lifecycle {
+	return &Lifecycle{Vouchers: vouchers, Now: time.Now}
+}
+
+// Subscribe registers a function that is called after every transition. Subscribers are called in the order they subscribed
+func (lc *Lifecycle) Subscribe(fn func(OrderEvent)) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.subscribers = append(lc.subscribers, fn)
+}
+
+// Transition moves the order to the state if it is allowed from the current state
+func (lc *Lifecycle) Transition(order *Order, to OrderState) error {
+	from := order.CurrentState()
+	if !CanTransition(from, to) {
+		return fmt.Errorf("%w: order %s from %s to %s", ErrInvalidTransition, order.ID, from, to)
+	}
+	order.State = to
+	event := OrderEvent{OrderID: order.ID, From: from, To: to, Time: lc.Now()}
+	lc.mu.RLock()
+	subscribers := lc.subscribers
+	lc.mu.RUnlock()
+	for _, fn := range subscribers {
+		fn(event)
+	}
+	return nil
+}
+
+// Price prices a draft or priced order with the Pricing policy and moves it to priced
+func (lc *Lifecycle) Price(order *Order) (PricingResult, error) {
+	if order.Frozen() {
+		return PricingResult{}, fmt.Errorf("%w: order %s is %s", ErrOrderFrozen, order.ID, order.CurrentState())
+	}
+	result, err := lc.Pricing.Price(order)
+	if err != nil {
+		return result, err
+	}
+	return result, lc.Transition(order, StatePriced)
+}
+
+// Lock freezes the pricing of the order, reserves the discount of the promotion that was applied when it was priced
+// from its budget and redeems it. Nothing is reserved or redeemed when adjustments replaced the promotions.
+// When another order spent the budget since the order was priced the reservation fails with ErrBudgetExhausted
+// and the order stays priced so it can be priced again
+func (lc *Lifecycle) Lock(order *Order) error {
+	if !CanTransition(order.CurrentState(), StateLocked) {
+		return fmt.Errorf("%w: order %s from %s to %s", ErrInvalidTransition, order.ID, order.CurrentState(), StateLocked)
+	}
+	if applied := order.Applied; applied.PromID != "" && applied.Discount > 0 {
+		if lc.Pricing.Budgets != nil {
+			if err := lc.Pricing.Budgets.Reserve(order.ID, applied.PromID, applied.Discount); err != nil {
+				return err
+			}
+		}
+		if lc.Vouchers != nil {
+			if err := lc.Vouchers.Redeem(order.ID, applied.PromID); err != nil {
+				if lc.Pricing.Budgets != nil {
+					lc.Pricing.Budgets.Release(order.ID)
+				}
+				return err
+			}
+		}
+	}
+	return lc.Transition(order, StateLocked)
+}
+
+func (lc *Lifecycle) Pay(order *Order) error {
+	return lc.Transition(order, StatePaid)
+}
+
+// Refund records a refund of the amount. The order is refunded once the whole payable amount is returned.
+// Amounts are compared in satang so refunds like 0.1 and 0.2 of a payable of 0.3 add up to the whole amount
+func (lc *Lifecycle) Refund(order *Order, amount float64) error {
+	satang := int64(math.Round(amount * 100))
+	if satang <= 0 {
+		return fmt.Errorf("refund of order %s must be at least 0.01, got %.2f", order.ID, amount)
+	}
+	payable := int64(math.Round(order.Payable() * 100))
+	refunded := int64(math.Round(order.Refunded*100)) + satang
+	if refunded > payable {
+		return fmt.Errorf("refund of %.2f on order %s is more than the remaining %.2f", amount, order.ID, float64(payable-refunded+satang)/100)
+	}
+	to := StatePartiallyRefunded
+	if refunded == payable {
+		to = StateRefunded
+	}
+	if err := lc.Transition(order, to); err != nil {
+		return err
+	}
+	// Refunded is kept in whole satang, a full refund is exactly the payable amount
+	order.Refunded = float64(refunded) / 100
+	if to == StateRefunded {
+		order.Refunded = order.Payable()
+	}
+	return nil
+}
+
+// Cancel cancels the order and releases its voucher redemptions and budget reservations.
+// Refunds don't give the budget back, the discount was given when the order was paid
+func (lc *Lifecycle) Cancel(order *Order) error {
+	if err := lc.Transition(order, StateCancelled); err != nil {
+		return err
+	}
+	if lc.Pricing.Budgets != nil {
+		if err := lc.Pricing.Budgets.Release(order.ID); err != nil {
+			return err
+		}
+	}
+	if lc.Vouchers != nil {
+		return lc.Vouchers.Release(order.ID)
+	}
+	return nil
+}
+
+// MemoryVoucherLedger counts the redemptions of every PromID in memory
+type MemoryVoucherLedger struct {
+	mu          sync.Mutex
+	redemptions map[string][]string // PromIDs redeemed by each order
+}
+
+func NewMemoryVoucherLedger() *MemoryVoucherLedger {
+	return &MemoryVoucherLedger{redemptions: make(map[string][]string)}
+}
+
+func (ledger *MemoryVoucherLedger) Redeem(orderID, promID string) error {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+	ledger.redemptions[orderID] = append(ledger.redemptions[orderID], promID)
+	return nil
+}
+
+func (ledger *MemoryVoucherLedger) Release(orderID string) error {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+	delete(ledger.redemptions, orderID)
+	return nil
+}
+
+// Redemptions returns the number of active redemptions of the PromID
+func (ledger *MemoryVoucherLedger) Redemptions(promID string) int {
+	ledger.mu.Lock()
+	defer ledger.mu.Unlock()
+	count := 0
+	for _, promIDs := range ledger.redemptions {
+		for _, id := range promIDs {
+			if id == promID {
+				count++
+			}
+		}
+	}
+	return count
+}
@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+func lifecycleOrder() Order {
+	return Order{
+		ID: "1",
+		Items: []Item{
+			{SKU: "A", Price: 500, Amount: 2, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		},
+		Promotions: []Promotion{
+			{PromName: "100 Baht off over 1000", PromID: "D100"},
+		},
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	t.Run("Draft to refunded", func(t *testing.T) {
+		lc := NewLifecycle(nil)
+		var events []OrderEvent
+		lc.Subscribe(func(event OrderEvent) { events = append(events, event) })
+		order := lifecycleOrder()
+		lc.Price(&order)
+		lc.Lock(&order)
+		lc.Pay(&order)
+		// Payable is 900, refunding 400 then 500 refunds the whole order
+		if err := lc.Refund(&order, 400); err != nil || order.State != StatePartiallyRefunded {
+			t.Errorf("Expected partially refunded, got %s and %v", order.State, err)
+		}
+		if err := lc.Refund(&order, 600); err == nil {
+			t.Errorf("Expected error for refunding more than the remaining 500")
+		}
+		if err := lc.Refund(&order, 500); err != nil || order.State != StateRefunded {
+			t.Errorf("Expected refunded, got %s and %v", order.State, err)
+		}
+		expected := []OrderState{StatePriced, StateLocked, StatePaid, StatePartiallyRefunded, StateRefunded}
+		if len(events) != len(expected) {
+			t.Fatalf("Expected %d events, got %v", len(expected), events)
+		}
+		for i, state := range expected {
+			if events[i].To != state {
+				t.Errorf("Expected event %d to be %s, got %s", i, state, events[i].To)
+			}
+		}
+		if events[0].From != StateDraft {
+			t.Errorf("Expected the first event from draft, got %s", events[0].From)
+		}
+	})
+	t.Run("Refunds add up in satang", func(t *testing.T) {
+		lc := NewLifecycle(nil)
+		order := Order{ID: "2", Items: []Item{
+			{SKU: "A", Price: 0.3, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		}}
+		lc.Price(&order)
+		lc.Lock(&order)
+		lc.Pay(&order)
+		// 0.1 + 0.2 is 0.30000000000000004 in float
+		if err := lc.Refund(&order, 0.1); err != nil || order.State != StatePartiallyRefunded {
+			t.Errorf("Expected partially refunded, got %s and %v", order.State, err)
+		}
+		if err := lc.Refund(&order, 0.2); err != nil || order.State != StateRefunded {
+			t.Errorf("Expected refunded, got %s and %v", order.State, err)
+		}
+		if order.Refunded != order.Payable() {
+			t.Errorf("Expected %f refunded, got %f", order.Payable(), order.Refunded)
+		}
+		if err := lc.Refund(&order, 0.004); err == nil {
+			t.Errorf("Expected error for a refund under a satang")
+		}
+	})
+	t.Run("Pricing freezes once locked", func(t *testing.T) {
+		lc := NewLifecycle(nil)
+		order := lifecycleOrder()
+		lc.Price(&order)
+		lc.Lock(&order)
+		order.Items[0].Amount = 10
+		order.Promotions = append(order.Promotions, Promotion{PromName: "50% Off", PromID: "HOFF"})
+		order.CalcTotal()
+		order.CalcDiscount()
+		// The total and discount stay the same as when the order was locked
+		if order.Total != 1000 || order.Discount != 100 {
+			t.Errorf("Expected total 1000 and discount 100, got %f and %f", order.Total, order.Discount)
+		}
+		if _, err := lc.Price(&order); !errors.Is(err, ErrOrderFrozen) {
+			t.Errorf("Expected ErrOrderFrozen, got %v", err)
+		}
+	})
+	t.Run("Invalid transitions", func(t *testing.T) {
+		lc := NewLifecycle(nil)
+		order := lifecycleOrder()
+		if err := lc.Pay(&order); !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("Expected ErrInvalidTransition for paying a draft, got %v", err)
+		}
+		lc.Cancel(&order)
+		if err := lc.Transition(&order, StateDraft); !errors.Is(err, ErrInvalidTransition) {
+			t.Errorf("Expected ErrInvalidTransition for a cancelled order, got %v", err)
+		}
+	})
+	t.Run("Cancelling releases the voucher", func(t *testing.T) {
+		ledger := NewMemoryVoucherLedger()
+		lc := NewLifecycle(ledger)
+		order := lifecycleOrder()
+		lc.Price(&order)
+		lc.Lock(&order)
+		if ledger.Redemptions("D100") != 1 {
+			t.Errorf("Expected D100 to be redeemed once, got %d", ledger.Redemptions("D100"))
+		}
+		lc.Cancel(&order)
+		if ledger.Redemptions("D100") != 0 {
+			t.Errorf("Expected D100 to be released, got %d", ledger.Redemptions("D100"))
+		}
+	})
+	t.Run("Locked cart can't be changed", func(t *testing.T) {
+		cart := NewCart("1", nil)
+		cart.AddItem(Item{SKU: "A", Price: 10, Amount: 1})
+		lc := NewLifecycle(nil)
+		lc.Price(&cart.Order)
+		lc.Lock(&cart.Order)
+		if err := cart.AddItem(Item{SKU: "B", Price: 10, Amount: 1}); !errors.Is(err, ErrOrderFrozen) {
+			t.Errorf("Expected ErrOrderFrozen, got %v", err)
+		}
+	})
+}
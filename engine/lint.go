@@ -0,0 +1,230 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+)
+
+// The linter checks a promotion set before it is deployed. Errors are promotions that are broken and can never give
+// the discount they were configured for, warnings are promotions that work but are likely not what was meant.
+// Some checks need the items that are sold, they are skipped when there is no catalog.
+
+// LintSeverity is how bad an issue is, only errors fail the lint command
+type LintSeverity string
+
+const (
+	LintError   LintSeverity = "error"
+	LintWarning LintSeverity = "warning"
+)
+
+// LintIssue is a problem with a promotion. Field is the path of the field in the promotion set, like Promotions[2].Percent
+type LintIssue struct {
+	Severity LintSeverity
+	Field    string
+	PromID   string
+	Message  string
+}
+
+func (issue LintIssue) String() string {
+	return fmt.Sprintf("%s: %s (%s): %s", issue.Severity, issue.Field, issue.PromID, issue.Message)
+}
+
+// LintOptions are what the promotions are checked against. Catalog is every item that is sold with its promotion flags,
+// Now is the deploy time for reporting promotions that have already expired
+type LintOptions struct {
+	Catalog []Item
+	Now     time.Time
+}
+
+// Lint returns the issues of the promotion set in the order of the promotions
+func Lint(promotions []Promotion, opts LintOptions) []LintIssue {
+	var issues []LintIssue
+	for i, prom := range promotions {
+		field := fmt.Sprintf("Promotions[%d]", i)
+		add := func(severity LintSeverity, name, message string) {
+			issues = append(issues, LintIssue{Severity: severity, Field: field + name, PromID: prom.PromID, Message: message})
+		}
+		for _, err := range validatePromotion(field, prom) {
+			issues = append(issues, LintIssue{Severity: LintError, Field: err.Field, PromID: prom.PromID, Message: err.Reason})
+		}
+		if prom.PromName == "" {
+			add(LintWarning, ".PromName", "is empty, the receipt will show no name for the promotion")
+		}
+		if prom.Percent != 0 && prom.PromID != "WPCT" && prom.PromID != "PCTO" {
+			add(LintWarning, ".Percent", fmt.Sprintf("is ignored by %s", prom.PromID))
+		}
+		if prom.Value != 0 && prom.PromID != "BAHT" {
+			add(LintWarning, ".Value", fmt.Sprintf("is ignored by %s", prom.PromID))
+		}
+		if methods := prom.Payment.Methods; len(methods) > 0 {
+			if len(prom.Payment.BINs) > 0 && !containsMethod(methods, PayCard) {
+				add(LintError, ".Payment.BINs", "can never be met, card isn't one of the payment methods")
+			}
+			if len(prom.Payment.Wallets) > 0 && !containsMethod(methods, PayWallet) {
+				add(LintError, ".Payment.Wallets", "can never be met, wallet isn't one of the payment methods")
+			}
+		}
+		if len(prom.Payment.BINs) > 0 && len(prom.Payment.Wallets) > 0 {
+			add(LintError, ".Payment", "can never be met, a payment can't be both a card and a wallet")
+		}
+		if prom.Customer.FirstOrder && prom.Customer.InactiveDays > 0 {
+			add(LintError, ".Customer", "can never be met, a first order can't be from a customer who hasn't ordered in a while")
+		}
+		if prom.PromID == "D100" && prom.Cap > 0 && prom.Cap < 100 {
+			add(LintWarning, ".Cap", fmt.Sprintf("%.2f is lower than the 100 Baht of D100", prom.Cap))
+		}
+
+		if !prom.ValidFrom.IsZero() && !prom.ValidUntil.IsZero() && !prom.ValidUntil.After(prom.ValidFrom) {
+			add(LintError, ".ValidUntil", fmt.Sprintf("window never opens, %s is not after ValidFrom %s", prom.ValidUntil.Format(time.RFC3339), prom.ValidFrom.Format(time.RFC3339)))
+		} else if !opts.Now.IsZero() && !prom.ValidUntil.IsZero() && !opts.Now.Before(prom.ValidUntil) {
+			add(LintWarning, ".ValidUntil", "has already expired at "+prom.ValidUntil.Format(time.RFC3339))
+		}
+
+		// Only the best promotion of an order is applied, so two of the same PromID at the same time compete with each other
+		for j := 0; j < i; j++ {
+			other := promotions[j]
+			if other.PromID != prom.PromID || !windowsOverlap(prom, other) || !scopesOverlap(prom.Scope, other.Scope) {
+				continue
+			}
+			if reflect.DeepEqual(other, prom) {
+				add(LintWarning, "", fmt.Sprintf("is a duplicate of Promotions[%d]", j))
+			} else {
+				add(LintWarning, "", fmt.Sprintf("overlaps with Promotions[%d] of the same PromID, only the bigger discount of the two is applied", j))
+			}
+		}
+
+		if opts.Catalog != nil {
+			for _, message := range prom.lintCatalog(opts.Catalog) {
+				add(LintError, "", message)
+			}
+			if prom.PromID == "B2I1" && freeItemsAreQualifiers(opts.Catalog) {
+				add(LintWarning, "", "every free item is also a selected item, the customer never gets a separate item for free")
+			}
+		}
+	}
+	return issues
+}
+
+// LintErrors counts the issues that are errors
+func LintErrors(issues []LintIssue) int {
+	count := 0
+	for _, issue := range issues {
+		if issue.Severity == LintError {
+			count++
+		}
+	}
+	return count
+}
+
+// windowsOverlap reports if both promotions are valid at the same time, a zero time leaves that side of the window open
+func windowsOverlap(a, b Promotion) bool {
+	if !a.ValidUntil.IsZero() && !b.ValidFrom.IsZero() && !a.ValidUntil.After(b.ValidFrom) {
+		return false
+	}
+	if !b.ValidUntil.IsZero() && !a.ValidFrom.IsZero() && !b.ValidUntil.After(a.ValidFrom) {
+		return false
+	}
+	return true
+}
+
+func containsMethod(methods []PaymentMethod, method PaymentMethod) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// scopesOverlap reports if an order can be in both scopes. Scopes with different channels, stores, regions or zones never overlap
+func scopesOverlap(a, b Scope) bool {
+	pairs := [][2][]string{{a.channels(), b.channels()}, {a.Stores, b.Stores}, {a.Regions, b.Regions}, {a.Zones, b.Zones}}
+	for _, pair := range pairs {
+		if len(pair[0]) == 0 || len(pair[1]) == 0 {
+			continue
+		}
+		shared := false
+		for _, value := range pair[0] {
+			if contains(pair[1], value) {
+				shared = true
+			}
+		}
+		if !shared {
+			return false
+		}
+	}
+	return true
+}
+
+// lintCatalog returns why no order of items from the catalog can ever meet the condition of the promotion
+func (prom Promotion) lintCatalog(catalog []Item) []string {
+	var pieces, weighted, fiftyOff, free int
+	skus, selected := map[string]bool{}, map[string]bool{}
+	for _, item := range catalog {
+		skus[item.SKU] = true
+		if item.Weighted() {
+			weighted++
+		} else {
+			pieces++
+			if item.ValidFiftyOff {
+				fiftyOff++
+			}
+			if item.ValidFreeItem {
+				free++
+			}
+		}
+		if item.ValidSelectedItem {
+			selected[item.SKU] = true
+		}
+	}
+	var messages []string
+	switch prom.PromID {
+	case "B2G1", "B1N1":
+		if pieces == 0 {
+			messages = append(messages, "threshold can never be met, the catalog has no items sold per piece")
+		}
+	case "B1NH":
+		if fiftyOff == 0 {
+			messages = append(messages, "threshold can never be met, no item sold per piece in the catalog is ValidFiftyOff")
+		} else if len(skus) < 2 {
+			messages = append(messages, "threshold can never be met, it needs two different items and the catalog has one")
+		}
+	case "B2I1":
+		// Lines of the same SKU are merged so the two selected items must be different SKUs
+		if len(selected) < 2 {
+			messages = append(messages, fmt.Sprintf("threshold can never be met, it needs two different selected items and the catalog has %d", len(selected)))
+		}
+		if free == 0 {
+			messages = append(messages, "no item sold per piece in the catalog is ValidFreeItem")
+		}
+	case "WPCT":
+		if weighted == 0 {
+			messages = append(messages, "threshold can never be met, the catalog has no weighted items")
+		}
+	}
+	return messages
+}
+
+func freeItemsAreQualifiers(catalog []Item) bool {
+	found := false
+	for _, item := range catalog {
+		if item.ValidFreeItem && !item.Weighted() {
+			if !item.ValidSelectedItem {
+				return false
+			}
+			found = true
+		}
+	}
+	return found
+}
+
+// PrintLint writes the issues and a count of errors and warnings
+func PrintLint(w io.Writer, issues []LintIssue) {
+	for _, issue := range issues {
+		fmt.Fprintln(w, issue)
+	}
+	count := LintErrors(issues)
+	fmt.Fprintf(w, "%d errors, %d warnings\n", count, len(issues)-count)
+}
@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLint(t *testing.T) {
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	mar := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	catalog := []Item{
+		{SKU: "A", Price: 50, Amount: 1, ValidSelectedItem: true, ValidFreeItem: true, ValidFiftyOff: false},
+		{SKU: "B", Price: 80, Amount: 1, ValidSelectedItem: true, ValidFreeItem: false, ValidFiftyOff: true},
+	}
+	lint := func(promotions []Promotion, catalog []Item) []LintIssue {
+		return Lint(promotions, LintOptions{Catalog: catalog, Now: jan})
+	}
+	expectIssue := func(t *testing.T, issues []LintIssue, severity LintSeverity, field string) {
+		t.Helper()
+		for _, issue := range issues {
+			if issue.Severity == severity && issue.Field == field {
+				return
+			}
+		}
+		t.Errorf("Expected %s on %s, got %v", severity, field, issues)
+	}
+
+	t.Run("Valid promotion set", func(t *testing.T) {
+		issues := lint([]Promotion{
+			{PromName: "Buy2Get1Free", PromID: "B2G1"},
+			{PromName: "New year 50% off", PromID: "HOFF", ValidFrom: jan, ValidUntil: feb},
+			{PromName: "Spring 50% off", PromID: "HOFF", ValidFrom: feb, ValidUntil: mar},
+		}, catalog)
+		if len(issues) != 0 {
+			t.Errorf("Expected no issues, got %v", issues)
+		}
+	})
+	t.Run("Percent above 100", func(t *testing.T) {
+		issues := lint([]Promotion{{PromName: "150% off produce", PromID: "WPCT", Percent: 150}}, nil)
+		expectIssue(t, issues, LintError, "Promotions[0].Percent")
+	})
+	t.Run("Window never opens", func(t *testing.T) {
+		issues := lint([]Promotion{{PromName: "50% Off", PromID: "HOFF", ValidFrom: feb, ValidUntil: feb}}, nil)
+		expectIssue(t, issues, LintError, "Promotions[0].ValidUntil")
+	})
+	t.Run("Already expired", func(t *testing.T) {
+		issues := Lint([]Promotion{{PromName: "50% Off", PromID: "HOFF", ValidFrom: jan, ValidUntil: feb}}, LintOptions{Now: mar})
+		expectIssue(t, issues, LintWarning, "Promotions[0].ValidUntil")
+	})
+	t.Run("Overlapping promotions of the same PromID", func(t *testing.T) {
+		issues := lint([]Promotion{
+			{PromName: "50% Off", PromID: "HOFF", ValidFrom: jan, ValidUntil: mar},
+			{PromName: "50% Off, max 500", PromID: "HOFF", Cap: 500, ValidFrom: feb},
+		}, nil)
+		expectIssue(t, issues, LintWarning, "Promotions[1]")
+	})
+	t.Run("Same PromID in different channels", func(t *testing.T) {
+		issues := lint([]Promotion{
+			{PromName: "Web 50% off", PromID: "HOFF", Scope: Scope{Channels: []Channel{ChannelWeb}}},
+			{PromName: "Store 50% off, max 500", PromID: "HOFF", Cap: 500, Scope: Scope{Channels: []Channel{ChannelStore}}},
+		}, nil)
+		if len(issues) != 0 {
+			t.Errorf("Expected no issues, got %v", issues)
+		}
+	})
+	t.Run("Threshold never met by the catalog", func(t *testing.T) {
+		issues := lint([]Promotion{
+			{PromName: "20% off produce", PromID: "WPCT", Percent: 20},
+			{PromName: "Buy A,B get C free", PromID: "B2I1"},
+		}, catalog[:1])
+		expectIssue(t, issues, LintError, "Promotions[0]")
+		expectIssue(t, issues, LintError, "Promotions[1]")
+	})
+	t.Run("Free item is also the qualifier", func(t *testing.T) {
+		issues := lint([]Promotion{{PromName: "Buy A,B get C free", PromID: "B2I1"}}, catalog)
+		expectIssue(t, issues, LintWarning, "Promotions[0]")
+		if LintErrors(issues) != 0 {
+			t.Errorf("Expected no errors, got %v", issues)
+		}
+	})
+	t.Run("Unknown PromID", func(t *testing.T) {
+		issues := lint([]Promotion{{PromName: "Mystery", PromID: "NOPE"}}, nil)
+		expectIssue(t, issues, LintError, "Promotions[0].PromID")
+	})
+}
@@ -0,0 +1,202 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Locale is the language and formatting of receipts, like th for Thai. Region suffixes like th-TH use their language.
+// The empty locale keeps the plain amounts with two decimals and ISO dates that were printed before receipts were localised
+type Locale string
+
+const (
+	LocalePlain   Locale = ""
+	LocaleEnglish Locale = "en"
+	LocaleThai    Locale = "th"
+)
+
+// localeFormat is how a language writes amounts and dates and the translation of the receipt labels.
+// Labels are keyed by the English label, labels without a translation are shown in English
+type localeFormat struct {
+	Currency    string // Written in front of amounts
+	Group       string // Thousands separator
+	Decimal     string
+	BuddhistEra bool           // Years are counted from 543 BC, 2024 is 2567
+	Location    *time.Location // Time zone the dates are written in, the zone of the time when nil
+	Months      [12]string
+	Labels      map[string]string
+}
+
+var locales = map[Locale]localeFormat{
+	LocaleEnglish: {
+		Currency: "฿",
+		Group:    ",",
+		Decimal:  ".",
+		Location: bangkok,
+		Months:   [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+	},
+	LocaleThai: {
+		Currency:    "฿",
+		Group:       ",",
+		Decimal:     ".",
+		BuddhistEra: true,
+		Location:    bangkok,
+		Months:      [12]string{"ม.ค.", "ก.พ.", "มี.ค.", "เม.ย.", "พ.ค.", "มิ.ย.", "ก.ค.", "ส.ค.", "ก.ย.", "ต.ค.", "พ.ย.", "ธ.ค."},
+		Labels: map[string]string{
+			"Order ID":          "เลขที่ใบสั่งซื้อ",
+			"Order":             "เลขที่",
+			"Receipt":           "ใบเสร็จรับเงิน",
+			"Item":              "สินค้า",
+			"Quantity":          "จำนวน",
+			"Price":             "ราคา",
+			"Amount":            "จำนวนเงิน",
+			"Subtotal":          "รวมเป็นเงิน",
+			"Discount":          "ส่วนลด",
+			"Rounding":          "ปัดเศษ",
+			"Total":             "ยอดรวม",
+			"Total Payable":     "ยอดชำระ",
+			"VAT %g%% included": "รวมภาษีมูลค่าเพิ่ม %g%%",
+			"You saved":         "ประหยัดไป",
+		},
+	},
+}
+
+// bangkok is the time zone of the stores. Thailand has no daylight saving time, so the fixed zone is used when the
+// system has no time zone database
+var bangkok = func() *time.Location {
+	if location, err := time.LoadLocation("Asia/Bangkok"); err == nil {
+		return location
+	}
+	return time.FixedZone("ICT", 7*60*60)
+}()
+
+// language returns the locale without its region, th-TH and th_TH are th
+func (locale Locale) language() Locale {
+	language := strings.ToLower(string(locale))
+	if i := strings.IndexAny(language, "-_"); i >= 0 {
+		language = language[:i]
+	}
+	return Locale(language)
+}
+
+func (locale Locale) Valid() bool {
+	if locale == LocalePlain {
+		return true
+	}
+	_, ok := locales[locale.language()]
+	return ok
+}
+
+// format returns the format of the language of the locale, ok is false for the plain locale and unknown locales
+func (locale Locale) format() (localeFormat, bool) {
+	format, ok := locales[locale.language()]
+	return format, ok
+}
+
+// Label translates an English receipt label
+func (locale Locale) Label(label string) string {
+	format, _ := locale.format()
+	if translated, ok := format.Labels[label]; ok {
+		return translated
+	}
+	return label
+}
+
+// Number writes the number with decimals and thousands separators, 1234.5 with 2 decimals is 1,234.50
+func (locale Locale) Number(number float64, decimals int) string {
+	s := fmt.Sprintf("%.*f", decimals, math.Abs(number))
+	format, ok := locale.format()
+	if !ok {
+		return fmt.Sprintf("%.*f", decimals, number)
+	}
+	whole, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, fraction = s[:i], s[i+1:]
+	}
+	var b strings.Builder
+	if number < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteString("-")
+	}
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(format.Group)
+		}
+		b.WriteRune(digit)
+	}
+	if fraction != "" {
+		b.WriteString(format.Decimal)
+		b.WriteString(fraction)
+	}
+	return b.String()
+}
+
+// Money writes the amount in Baht with the currency symbol, like ฿1,234.50 and -฿25.00
+func (locale Locale) Money(amount float64) string {
+	format, ok := locale.format()
+	if !ok {
+		return fmt.Sprintf("%.2f", amount)
+	}
+	s := locale.Number(amount, 2)
+	if strings.HasPrefix(s, "-") {
+		return "-" + format.Currency + s[1:]
+	}
+	return format.Currency + s
+}
+
+// Date writes the date and time of receipts, like 10 May 2024 14:30 in English and 10 พ.ค. 2567 14:30 in Thai.
+// Dates are in Bangkok time whatever the zone of t, so a receipt printed from a UTC timestamp shows the time of the store
+func (locale Locale) Date(t time.Time) string {
+	format, ok := locale.format()
+	if !ok {
+		return t.Format("2006-01-02 15:04")
+	}
+	if format.Location != nil {
+		t = t.In(format.Location)
+	}
+	year := t.Year()
+	if format.BuddhistEra {
+		year += 543
+	}
+	return fmt.Sprintf("%d %s %d %s", t.Day(), format.Months[t.Month()-1], year, t.Format("15:04"))
+}
+
+// Name is the name of the promotion in the locale. Without a name for the locale it falls back to the name of its language,
+// then the English name and last to PromName
+func (prom Promotion) Name(locale Locale) string {
+	if name := localised(prom.Names, locale); name != "" {
+		return name
+	}
+	return prom.PromName
+}
+
+// Description is the description of the promotion in the locale, with the same fallbacks as Name but empty without any
+func (prom Promotion) Description(locale Locale) string {
+	return localised(prom.Descriptions, locale)
+}
+
+func localised(texts map[Locale]string, locale Locale) string {
+	for _, candidate := range []Locale{locale, locale.language(), LocaleEnglish} {
+		if text, ok := texts[candidate]; ok && text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+func validateLocales(field string, texts map[Locale]string) ValidationErrors {
+	keys := make([]string, 0, len(texts))
+	for locale := range texts {
+		keys = append(keys, string(locale))
+	}
+	sort.Strings(keys)
+	var errs ValidationErrors
+	for _, locale := range keys {
+		if locale == "" || !Locale(locale).Valid() {
+			errs = append(errs, ValidationError{Field: fmt.Sprintf("%s[%s]", field, locale), Reason: fmt.Sprintf("unknown locale %q", locale)})
+		}
+	}
+	return errs
+}
@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLocaleFormatting(t *testing.T) {
+	t.Run("Money", func(t *testing.T) {
+		cases := []struct {
+			locale Locale
+			amount float64
+			want   string
+		}{
+			{LocaleThai, 1234.5, "฿1,234.50"},
+			{LocaleEnglish, 1234567.891, "฿1,234,567.89"},
+			{LocaleThai, 999.999, "฿1,000.00"},
+			{LocaleThai, -25, "-฿25.00"},
+			{LocaleThai, -0.001, "฿0.00"},
+			{"th-TH", 100, "฿100.00"},
+			{LocalePlain, 1234.5, "1234.50"},
+		}
+		for _, c := range cases {
+			if got := c.locale.Money(c.amount); got != c.want {
+				t.Errorf("Expected %s for %f in %q, got %s", c.want, c.amount, c.locale, got)
+			}
+		}
+	})
+	t.Run("Number", func(t *testing.T) {
+		if got := LocaleThai.Number(1250.5, 3); got != "1,250.500" {
+			t.Errorf("Expected 1,250.500, got %s", got)
+		}
+		if got := LocaleEnglish.Number(1000000, 0); got != "1,000,000" {
+			t.Errorf("Expected 1,000,000, got %s", got)
+		}
+	})
+	t.Run("Buddhist era dates", func(t *testing.T) {
+		at := time.Date(2024, 5, 10, 14, 30, 0, 0, time.UTC)
+		// 14:30 UTC is 21:30 in Bangkok
+		if got := LocaleThai.Date(at); got != "10 พ.ค. 2567 21:30" {
+			t.Errorf("Expected 10 พ.ค. 2567 21:30, got %s", got)
+		}
+		if got := LocaleThai.Date(time.Date(2024, 5, 10, 20, 0, 0, 0, time.UTC)); got != "11 พ.ค. 2567 03:00" {
+			t.Errorf("Expected the next day in Bangkok, got %s", got)
+		}
+		if got := LocaleEnglish.Date(at); got != "10 May 2024 21:30" {
+			t.Errorf("Expected 10 May 2024 21:30, got %s", got)
+		}
+		if got := LocalePlain.Date(at); got != "2024-05-10 14:30" {
+			t.Errorf("Expected 2024-05-10 14:30, got %s", got)
+		}
+	})
+	t.Run("Labels", func(t *testing.T) {
+		if got := LocaleThai.Label("Total"); got != "ยอดรวม" {
+			t.Errorf("Expected the Thai label, got %s", got)
+		}
+		if got := LocaleThai.Label("Not translated"); got != "Not translated" {
+			t.Errorf("Expected the English label without a translation, got %s", got)
+		}
+	})
+}
+
+func TestPromotionNames(t *testing.T) {
+	prom := Promotion{PromName: "Buy 2 get 1 free", PromID: "B2G1",
+		Names:        map[Locale]string{LocaleThai: "ซื้อ 2 แถม 1", LocaleEnglish: "Buy 2, get 1 free"},
+		Descriptions: map[Locale]string{LocaleEnglish: "The cheapest item is free"}}
+	cases := []struct {
+		locale            Locale
+		name, description string
+	}{
+		{LocaleThai, "ซื้อ 2 แถม 1", "The cheapest item is free"},
+		{"th-TH", "ซื้อ 2 แถม 1", "The cheapest item is free"},
+		{LocaleEnglish, "Buy 2, get 1 free", "The cheapest item is free"},
+		{"ja", "Buy 2, get 1 free", "The cheapest item is free"},
+	}
+	for _, c := range cases {
+		if got := prom.Name(c.locale); got != c.name {
+			t.Errorf("Expected name %s in %q, got %s", c.name, c.locale, got)
+		}
+		if got := prom.Description(c.locale); got != c.description {
+			t.Errorf("Expected description %s in %q, got %s", c.description, c.locale, got)
+		}
+	}
+	if got := (Promotion{PromName: "Buy 2 get 1 free"}).Name(LocaleThai); got != "Buy 2 get 1 free" {
+		t.Errorf("Expected PromName without localised names, got %s", got)
+	}
+
+	t.Run("Validation", func(t *testing.T) {
+		order := Order{ID: "L1", Locale: "xx", Items: []Item{{SKU: "A", Price: 10, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false}},
+			Promotions: []Promotion{{PromName: "Half off", PromID: "HOFF", Names: map[Locale]string{"jp": "半額"}}}}
+		errs, _ := order.Validate().(ValidationErrors)
+		fields := map[string]bool{}
+		for _, err := range errs {
+			fields[err.Field] = true
+		}
+		if !fields["Locale"] || !fields["Promotions[0].Names[jp]"] {
+			t.Errorf("Expected errors for the locale of the order and the name, got %v", errs)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// SlogObserver writes structured logs of the pricing of orders. Every record has the order_id so the logs of an order can be
+// followed across services. Evaluated promotions are logged at debug level, priced orders at info and errors at error level.
+// Any slog.Handler can be plugged in through the Logger, like a JSON handler for the log pipeline
+type SlogObserver struct {
+	Logger *slog.Logger
+}
+
+// NewSlogObserver logs with the logger, or with slog.Default() when it is nil
+func NewSlogObserver(logger *slog.Logger) SlogObserver {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return SlogObserver{Logger: logger}
+}
+
+// forOrder returns the logger with the order_id and customer_id of the order
+func (observer SlogObserver) forOrder(order *Order) *slog.Logger {
+	logger := observer.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger = logger.With(slog.String("order_id", order.ID))
+	if order.CustomerID != "" {
+		logger = logger.With(slog.String("customer_id", order.CustomerID))
+	}
+	return logger
+}
+
+func (observer SlogObserver) PromotionEvaluated(order *Order, result PromotionResult, elapsed time.Duration) {
+	logger := observer.forOrder(order)
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	attrs := []any{slog.String("prom_id", result.PromID), slog.Float64("discount", result.Discount), slog.Duration("elapsed", elapsed)}
+	if result.Reason != "" {
+		attrs = append(attrs, slog.String("reason", result.Reason))
+	}
+	logger.Debug("promotion evaluated", attrs...)
+}
+
+func (observer SlogObserver) OrderPriced(order *Order, result PricingResult, err error, elapsed time.Duration) {
+	logger := observer.forOrder(order)
+	if err != nil {
+		logger.Error("pricing failed", slog.String("error", err.Error()), slog.String("kind", errorKind(err)), slog.Duration("elapsed", elapsed))
+		return
+	}
+	logger.Info("order priced",
+		slog.Float64("total", result.Total),
+		slog.Float64("discount", result.Discount),
+		slog.String("applied", result.Applied.PromID),
+		slog.Int("promotions", len(result.Breakdown)),
+		slog.Duration("elapsed", elapsed))
+}
@@ -0,0 +1,47 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogObserver(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	order := Order{ID: "L1", CustomerID: "C1", Observer: NewSlogObserver(logger), Items: []Item{
+		{SKU: "A", Price: 100, Amount: 3, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+	}, Promotions: []Promotion{{PromName: "Buy 2 get 1 free", PromID: "B2G1"}}}
+	order.Price()
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Expected JSON records, got %q", line)
+		}
+		records = append(records, record)
+	}
+	if len(records) != 2 || records[0]["msg"] != "promotion evaluated" || records[1]["msg"] != "order priced" {
+		t.Fatalf("Expected the evaluated promotion and the priced order, got %v", records)
+	}
+	for _, record := range records {
+		if record["order_id"] != "L1" || record["customer_id"] != "C1" {
+			t.Errorf("Expected every record with the order and customer, got %v", record)
+		}
+	}
+	if records[1]["applied"] != "B2G1" || records[1]["discount"] != 100.0 {
+		t.Errorf("Expected the applied promotion and discount, got %v", records[1])
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		buf.Reset()
+		order := Order{ID: "L2", Observer: NewSlogObserver(logger), Items: []Item{{SKU: "A", Price: -1, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false}}}
+		order.Price()
+		if !strings.Contains(buf.String(), `"level":"ERROR"`) || !strings.Contains(buf.String(), `"kind":"invalid_order"`) {
+			t.Errorf("Expected an error record, got %s", buf.String())
+		}
+	})
+}
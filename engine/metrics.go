@@ -0,0 +1,205 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PricingObserver is told about every promotion evaluated while an order is priced and about the outcome of the pricing.
+// It is set as the Observer of orders, like Metrics or a SlogObserver, and is called on the goroutine that prices the order
+type PricingObserver interface {
+	PromotionEvaluated(order *Order, result PromotionResult, elapsed time.Duration)
+	OrderPriced(order *Order, result PricingResult, err error, elapsed time.Duration)
+}
+
+// PricingObservers tells every observer in order, for both metrics and logs
+type PricingObservers []PricingObserver
+
+func (observers PricingObservers) PromotionEvaluated(order *Order, result PromotionResult, elapsed time.Duration) {
+	for _, observer := range observers {
+		observer.PromotionEvaluated(order, result, elapsed)
+	}
+}
+
+func (observers PricingObservers) OrderPriced(order *Order, result PricingResult, err error, elapsed time.Duration) {
+	for _, observer := range observers {
+		observer.OrderPriced(order, result, err, elapsed)
+	}
+}
+
+// Buckets of the histograms, the evaluation of a rule takes microseconds and discounts are in Baht
+var (
+	latencyBuckets  = []float64{0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.05, 0.1}
+	discountBuckets = []float64{10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+)
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64 // Observations of at most the bucket, the last count is +Inf
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets)+1)}
+}
+
+func (h *histogram) observe(value float64) {
+	i := sort.SearchFloat64s(h.buckets, value)
+	h.counts[i]++
+	h.sum += value
+	h.count++
+}
+
+// Metrics counts the pricing of orders and writes them in the Prometheus text format. Every metric is labelled with the PromID
+// of the promotion, errors are labelled with their kind. Metrics is a PricingObserver and serves /metrics as an http.Handler.
+//
+//	metrics := NewMetrics()
+//	http.Handle("/metrics", metrics)
+//	order.Observer = PricingObservers{metrics, NewSlogObserver(logger)}
+type Metrics struct {
+	mu         sync.Mutex
+	evaluation map[string]*histogram // Latency of the rule of every PromID in seconds
+	evaluated  map[string]uint64
+	applied    map[string]uint64
+	rejected   map[string]uint64 // The order wasn't eligible for the promotion
+	discounts  map[string]*histogram
+	pricing    *histogram
+	errors     map[string]uint64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		evaluation: make(map[string]*histogram),
+		evaluated:  make(map[string]uint64),
+		applied:    make(map[string]uint64),
+		rejected:   make(map[string]uint64),
+		discounts:  make(map[string]*histogram),
+		pricing:    newHistogram(latencyBuckets),
+		errors:     make(map[string]uint64),
+	}
+}
+
+func (metrics *Metrics) PromotionEvaluated(order *Order, result PromotionResult, elapsed time.Duration) {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	if metrics.evaluation[result.PromID] == nil {
+		metrics.evaluation[result.PromID] = newHistogram(latencyBuckets)
+	}
+	metrics.evaluation[result.PromID].observe(elapsed.Seconds())
+	metrics.evaluated[result.PromID]++
+	if result.Reason != "" {
+		metrics.rejected[result.PromID]++
+	}
+}
+
+func (metrics *Metrics) OrderPriced(order *Order, result PricingResult, err error, elapsed time.Duration) {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	metrics.pricing.observe(elapsed.Seconds())
+	if err != nil {
+		metrics.errors[errorKind(err)]++
+		return
+	}
+	if result.Applied.PromID == "" {
+		return
+	}
+	metrics.applied[result.Applied.PromID]++
+	if metrics.discounts[result.Applied.PromID] == nil {
+		metrics.discounts[result.Applied.PromID] = newHistogram(discountBuckets)
+	}
+	metrics.discounts[result.Applied.PromID].observe(result.Applied.Discount)
+}
+
+// errorKind is the label of an error, kept to a few values so the number of series stays small
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, ErrInvalidOrder):
+		return "invalid_order"
+	case errors.Is(err, ErrOrderFrozen):
+		return "frozen"
+	}
+	return "other"
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format, series are sorted by their labels
+func (metrics *Metrics) WritePrometheus(w io.Writer) error {
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	var b strings.Builder
+	writeHistograms(&b, "altpromotions_rule_evaluation_seconds", "Time to evaluate the rule of a promotion for an order.", "prom_id", metrics.evaluation)
+	writeCounters(&b, "altpromotions_promotions_evaluated_total", "Promotions evaluated for an order.", "prom_id", metrics.evaluated)
+	writeCounters(&b, "altpromotions_promotions_applied_total", "Promotions applied as the best discount of an order.", "prom_id", metrics.applied)
+	writeCounters(&b, "altpromotions_promotions_rejected_total", "Promotions the order wasn't eligible for.", "prom_id", metrics.rejected)
+	writeHistograms(&b, "altpromotions_discount_baht", "Discount of the applied promotion in Baht.", "prom_id", metrics.discounts)
+	writeHistograms(&b, "altpromotions_pricing_seconds", "Time to price an order.", "", map[string]*histogram{"": metrics.pricing})
+	writeCounters(&b, "altpromotions_pricing_errors_total", "Orders that couldn't be priced.", "kind", metrics.errors)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (metrics *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.WritePrometheus(w)
+}
+
+func writeCounters(b *strings.Builder, name, help, label string, counters map[string]uint64) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	keys := make([]string, 0, len(counters))
+	for key := range counters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, value := range keys {
+		fmt.Fprintf(b, "%s{%s} %d\n", name, labelPair(label, value), counters[value])
+	}
+}
+
+func writeHistograms(b *strings.Builder, name, help, label string, histograms map[string]*histogram) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	keys := make([]string, 0, len(histograms))
+	for key := range histograms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, value := range keys {
+		h := histograms[value]
+		labels := ""
+		if label != "" {
+			labels = labelPair(label, value) + ","
+		}
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(b, "%s_bucket{%sle=\"%s\"} %d\n", name, labels, formatFloat(bound), cumulative)
+		}
+		fmt.Fprintf(b, "%s_bucket{%sle=\"+Inf\"} %d\n", name, labels, h.count)
+		labels = strings.TrimSuffix(labels, ",")
+		if labels != "" {
+			labels = "{" + labels + "}"
+		}
+		fmt.Fprintf(b, "%s_sum%s %s\n", name, labels, formatFloat(h.sum))
+		fmt.Fprintf(b, "%s_count%s %d\n", name, labels, h.count)
+	}
+}
+
+// labelPair escapes the value the way the text format requires
+func labelPair(label, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return fmt.Sprintf("%s=\"%s\"", label, value)
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
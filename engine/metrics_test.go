@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	price := func(order Order) {
+		order.Observer = metrics
+		order.Price()
+	}
+	items := []Item{
+		{SKU: "A", Price: 100, Amount: 3, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+	}
+	price(Order{ID: "M1", Items: items, Promotions: []Promotion{{PromName: "Buy 2 get 1 free", PromID: "B2G1"}, {PromName: "Half off", PromID: "HOFF"}}})
+	price(Order{ID: "M2", Items: items, Promotions: []Promotion{{PromName: "Buy 2 get 1 free", PromID: "B2G1"}, {PromName: "Web only", PromID: "HOFF", Scope: Scope{Channels: []Channel{ChannelWeb}}}}})
+	price(Order{ID: "M3", Items: []Item{{SKU: "A", Price: -1, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false}}})
+	price(Order{ID: "M4", State: StateLocked, Items: items})
+
+	var buf bytes.Buffer
+	if err := metrics.WritePrometheus(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# TYPE altpromotions_rule_evaluation_seconds histogram",
+		`altpromotions_rule_evaluation_seconds_count{prom_id="B2G1"} 2`,
+		`altpromotions_rule_evaluation_seconds_bucket{prom_id="HOFF",le="+Inf"} 2`,
+		`altpromotions_promotions_evaluated_total{prom_id="B2G1"} 2`,
+		`altpromotions_promotions_evaluated_total{prom_id="HOFF"} 2`,
+		// HOFF gives 150 off on the first order and the second order isn't sold on the web
+		`altpromotions_promotions_applied_total{prom_id="B2G1"} 1`,
+		`altpromotions_promotions_applied_total{prom_id="HOFF"} 1`,
+		`altpromotions_promotions_rejected_total{prom_id="HOFF"} 1`,
+		`altpromotions_discount_baht_bucket{prom_id="B2G1",le="100"} 1`,
+		`altpromotions_discount_baht_bucket{prom_id="HOFF",le="100"} 0`,
+		`altpromotions_discount_baht_sum{prom_id="HOFF"} 150`,
+		`altpromotions_pricing_seconds_count 4`,
+		`altpromotions_pricing_errors_total{kind="invalid_order"} 1`,
+		`altpromotions_pricing_errors_total{kind="frozen"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %s, got\n%s", want, out)
+		}
+	}
+
+	t.Run("Buckets are cumulative", func(t *testing.T) {
+		h := newHistogram([]float64{1, 2})
+		for _, value := range []float64{0.5, 1, 1.5, 3} {
+			h.observe(value)
+		}
+		var b strings.Builder
+		writeHistograms(&b, "test", "Test.", "", map[string]*histogram{"": h})
+		for _, want := range []string{`test_bucket{le="1"} 2`, `test_bucket{le="2"} 3`, `test_bucket{le="+Inf"} 4`, "test_sum 6", "test_count 4"} {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("Expected %s, got\n%s", want, b.String())
+			}
+		}
+	})
+	t.Run("Label values are escaped", func(t *testing.T) {
+		if got := labelPair("prom_id", "a\"b\\c\nd"); got != `prom_id="a\"b\\c\nd"` {
+			t.Errorf("Expected the escaped value, got %s", got)
+		}
+	})
+}
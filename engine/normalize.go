@@ -0,0 +1,52 @@
+package engine
+
+// NormalizedLine is a line after lines of the same SKU and price are merged. Lines are the indexes of the original
+// lines in Order.Items so receipts can still show the lines the way they were entered
+type NormalizedLine struct {
+	Item  Item
+	Lines []int
+}
+
+// Normalize consolidates the lines by SKU, price and unit so promotions see 4 of SKU A whether the POS sent one line of 4 or two lines of 2.
+// The merged lines keep the position of the first line of the SKU. The promotion flags are merged so the line qualifies if any of its lines did.
+// The same SKU at different prices stays on separate lines because the price decides the discount.
+func Normalize(items []Item) []NormalizedLine {
+	type key struct {
+		sku   string
+		price float64
+		unit  Unit
+	}
+	lines := make([]NormalizedLine, 0, len(items))
+	index := make(map[key]int, len(items))
+	for i, item := range items {
+		k := key{item.SKU, item.Price, item.Unit}
+		j, ok := index[k]
+		if !ok {
+			index[k] = len(lines)
+			lines = append(lines, NormalizedLine{Item: item, Lines: []int{i}})
+			continue
+		}
+		merged := &lines[j].Item
+		merged.Amount += item.Amount
+		merged.Quantity += item.Quantity
+		merged.ValidSelectedItem = merged.ValidSelectedItem || item.ValidSelectedItem
+		merged.ValidFreeItem = merged.ValidFreeItem || item.ValidFreeItem
+		merged.ValidFiftyOff = merged.ValidFiftyOff || item.ValidFiftyOff
+		merged.Chosen = merged.Chosen || item.Chosen
+		lines[j].Lines = append(lines[j].Lines, i)
+	}
+	return lines
+}
+
+// normalized returns a copy of the order with the lines merged, which is what the promotions are evaluated on
+func (order Order) normalized() Order {
+	lines := Normalize(order.Items)
+	if len(lines) == len(order.Items) {
+		return order
+	}
+	order.Items = make([]Item, len(lines))
+	for i, line := range lines {
+		order.Items[i] = line.Item
+	}
+	return order
+}
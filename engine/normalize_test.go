@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	t.Run("Lines of the same SKU and price are merged", func(t *testing.T) {
+		lines := Normalize([]Item{
+			{SKU: "A", Price: 100, Amount: 2, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+			{SKU: "B", Price: 30, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+			{SKU: "A", Price: 100, Amount: 2, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: true},
+			{SKU: "A", Price: 90, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		})
+		// SKU A at 90 Baht stays on its own line
+		if len(lines) != 3 {
+			t.Fatalf("Expected 3 lines, got %v", lines)
+		}
+		if lines[0].Item.Amount != 4 || !lines[0].Item.ValidFiftyOff || len(lines[0].Lines) != 2 || lines[0].Lines[1] != 2 {
+			t.Errorf("Expected 4 of SKU A from lines 0 and 2, got %v", lines[0])
+		}
+		if lines[1].Item.SKU != "B" || lines[2].Lines[0] != 3 {
+			t.Errorf("Expected SKU B second and SKU A at 90 from line 3, got %v", lines)
+		}
+	})
+	t.Run("Promotions give the same discount however the cart was entered", func(t *testing.T) {
+		promotions := []Promotion{
+			{PromName: "Buy2Get1Free", PromID: "B2G1"},
+			{PromName: "Buy 1 get next 1 Baht", PromID: "B1N1"},
+		}
+		split := Order{
+			ID: "1",
+			Items: []Item{
+				{SKU: "A", Price: 200, Amount: 2, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+				{SKU: "A", Price: 200, Amount: 2, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+			},
+			Promotions: promotions,
+		}
+		single := Order{
+			ID: "2",
+			Items: []Item{
+				{SKU: "A", Price: 200, Amount: 4, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+			},
+			Promotions: promotions,
+		}
+		splitResult, err := split.Price()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		singleResult, _ := single.Price()
+		// Buy2Get1Free gives 200 off for 4 of SKU A in both orders
+		if splitResult.Discount != 200 || singleResult.Discount != 200 || splitResult.Total != singleResult.Total {
+			t.Errorf("Expected 200 discount for both, got %f and %f", splitResult.Discount, singleResult.Discount)
+		}
+		if len(splitResult.Lines) != 1 || len(splitResult.Lines[0].Lines) != 2 {
+			t.Errorf("Expected one normalized line from two lines, got %v", splitResult.Lines)
+		}
+		// The order keeps the lines as they were entered
+		if len(split.Items) != 2 {
+			t.Errorf("Expected the order to keep 2 lines, got %d", len(split.Items))
+		}
+	})
+}
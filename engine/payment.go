@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PaymentMethod is how the customer pays, empty until they choose
+type PaymentMethod string
+
+const (
+	PayCash      PaymentMethod = "cash"
+	PayCard      PaymentMethod = "card"
+	PayWallet    PaymentMethod = "wallet"
+	PayPromptPay PaymentMethod = "promptpay"
+)
+
+func (method PaymentMethod) Valid() bool {
+	switch method {
+	case "", PayCash, PayCard, PayWallet, PayPromptPay:
+		return true
+	}
+	return false
+}
+
+// Payment is how the order is paid. BIN is the first 6 or 8 digits of the card number for card payments,
+// Wallet is the provider of wallet payments like truemoney
+type Payment struct {
+	Method PaymentMethod
+	BIN    string
+	Wallet string
+}
+
+// BINRange is a range of card BINs of a bank, From and To have the same number of digits and are inclusive
+type BINRange struct {
+	From string
+	To   string
+}
+
+func (r BINRange) contains(bin string) bool {
+	if len(bin) < len(r.From) {
+		return false
+	}
+	// BINs of the same length compare as numbers when compared as strings
+	prefix := bin[:len(r.From)]
+	return prefix >= r.From && prefix <= r.To
+}
+
+// PaymentCondition limits a promotion to payment methods, card BIN ranges and wallet providers,
+// like 10% off with the cards of a bank. An empty list allows every value
+type PaymentCondition struct {
+	Methods []PaymentMethod
+	BINs    []BINRange
+	Wallets []string
+}
+
+// paymentEligible returns why the payment of the order doesn't meet the condition, or an empty string when it does
+func (cond PaymentCondition) paymentEligible(payment Payment) string {
+	if len(cond.Methods) == 0 && len(cond.BINs) == 0 && len(cond.Wallets) == 0 {
+		return ""
+	}
+	if payment.Method == "" {
+		return "order has no payment method"
+	}
+	if len(cond.Methods) > 0 {
+		allowed := false
+		for _, method := range cond.Methods {
+			allowed = allowed || method == payment.Method
+		}
+		if !allowed {
+			return fmt.Sprintf("not available when paying with %s", payment.Method)
+		}
+	}
+	if len(cond.BINs) > 0 {
+		if payment.Method != PayCard {
+			return "only when paying with a card"
+		}
+		allowed := false
+		for _, r := range cond.BINs {
+			allowed = allowed || r.contains(payment.BIN)
+		}
+		if !allowed {
+			return "not available for this card"
+		}
+	}
+	if len(cond.Wallets) > 0 {
+		if payment.Method != PayWallet {
+			return "only when paying with " + strings.Join(cond.Wallets, ", ")
+		}
+		if !contains(cond.Wallets, payment.Wallet) {
+			return fmt.Sprintf("not available for %s, only for %s", payment.Wallet, strings.Join(cond.Wallets, ", "))
+		}
+	}
+	return ""
+}
+
+func validatePayment(field string, payment Payment) ValidationErrors {
+	var errs ValidationErrors
+	if !payment.Method.Valid() {
+		errs = append(errs, ValidationError{Field: field + ".Method", Reason: fmt.Sprintf("unknown payment method %q", payment.Method)})
+	}
+	if payment.BIN != "" && (!digits(payment.BIN) || len(payment.BIN) < 6 || len(payment.BIN) > 8) {
+		errs = append(errs, ValidationError{Field: field + ".BIN", Reason: "must be 6 to 8 digits"})
+	}
+	return errs
+}
+
+func validatePaymentCondition(field string, cond PaymentCondition) ValidationErrors {
+	var errs ValidationErrors
+	for i, method := range cond.Methods {
+		if method == "" || !method.Valid() {
+			errs = append(errs, ValidationError{Field: fmt.Sprintf("%s.Methods[%d]", field, i), Reason: fmt.Sprintf("unknown payment method %q", method)})
+		}
+	}
+	for i, r := range cond.BINs {
+		if !digits(r.From) || !digits(r.To) || len(r.From) != len(r.To) || r.From > r.To {
+			errs = append(errs, ValidationError{Field: fmt.Sprintf("%s.BINs[%d]", field, i), Reason: fmt.Sprintf("must be two BINs of the same number of digits with From before To, got %s-%s", r.From, r.To)})
+		}
+	}
+	return errs
+}
+
+func digits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// TenderOption is the pricing of the order when it is paid with the payment. Saving is the extra discount compared to
+// paying without any payment linked promotion, for showing "pay with X to save Y" at checkout.
+// Payable is what the customer pays with the payment, after the rounding of its tender
+type TenderOption struct {
+	Payment  Payment
+	Discount float64
+	Payable  float64
+	Saving   float64
+	Applied  PromotionResult
+}
+
+// CompareTenders prices the order with the policy for every candidate payment and returns the options with the biggest saving first.
+// The order itself isn't changed
+func CompareTenders(order Order, tenders []Payment, policy PricingPolicy) ([]TenderOption, error) {
+	baseline := copyOrder(order)
+	baseline.Payment = Payment{}
+	base, err := policy.Price(&baseline)
+	if err != nil {
+		return nil, err
+	}
+	options := make([]TenderOption, 0, len(tenders))
+	for _, payment := range tenders {
+		candidate := copyOrder(order)
+		candidate.Payment = payment
+		result, err := policy.Price(&candidate)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, TenderOption{
+			Payment:  payment,
+			Discount: result.Discount,
+			Payable:  result.Payable(),
+			Saving:   result.Discount - base.Discount,
+			Applied:  result.Applied,
+		})
+	}
+	sort.SliceStable(options, func(i, j int) bool { return options[i].Saving > options[j].Saving })
+	return options, nil
+}
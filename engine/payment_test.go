@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestPayment(t *testing.T) {
+	kbank := PaymentCondition{Methods: []PaymentMethod{PayCard}, BINs: []BINRange{{From: "404586", To: "404590"}, {From: "521729", To: "521729"}}}
+	order := Order{ID: "1", Items: []Item{
+		{SKU: "A", Price: 8000, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+	}, Promotions: []Promotion{
+		{PromName: "100 Baht off over 1000", PromID: "D100"},
+		{PromName: "10% off with KBank cards, max 500 Baht", PromID: "PCTO", Percent: 10, Cap: 500, Payment: kbank},
+		{PromName: "5% off with TrueMoney", PromID: "PCTO", Percent: 5, Payment: PaymentCondition{Wallets: []string{"truemoney"}}},
+	}}
+	cases := []struct {
+		name     string
+		payment  Payment
+		discount float64
+	}{
+		{"No payment yet", Payment{}, 100},
+		{"Cash", Payment{Method: PayCash}, 100},
+		{"KBank card", Payment{Method: PayCard, BIN: "40458812"}, 500},
+		{"Other card", Payment{Method: PayCard, BIN: "411111"}, 100},
+		{"TrueMoney", Payment{Method: PayWallet, Wallet: "truemoney"}, 400},
+		{"Other wallet", Payment{Method: PayWallet, Wallet: "rabbit"}, 100},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			order := copyOrder(order)
+			order.Payment = c.payment
+			result, err := order.Price()
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if result.Discount != c.discount {
+				t.Errorf("Expected discount to be %f, got %f", c.discount, result.Discount)
+			}
+		})
+	}
+	t.Run("Best payment method", func(t *testing.T) {
+		options, err := CompareTenders(order, []Payment{
+			{Method: PayCash},
+			{Method: PayWallet, Wallet: "truemoney"},
+			{Method: PayCard, BIN: "52172900"},
+		}, PricingPolicy{})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if options[0].Payment.Method != PayCard || options[0].Saving != 400 || options[0].Payable != 7500 {
+			t.Errorf("Expected the KBank card to save 400 with 7500 payable, got %+v", options[0])
+		}
+		if options[2].Payment.Method != PayCash || options[2].Saving != 0 {
+			t.Errorf("Expected cash to save nothing, got %+v", options[2])
+		}
+		if order.Payment.Method != "" {
+			t.Errorf("Expected the order to be unchanged, got %+v", order.Payment)
+		}
+	})
+	t.Run("Payable is rounded for the tender", func(t *testing.T) {
+		order := Order{ID: "2", Items: []Item{{SKU: "A", Price: 100.1, Amount: 1}}}
+		options, err := CompareTenders(order, []Payment{{Method: PayCash}, {Method: PayCard, BIN: "52172900"}}, PricingPolicy{Rounding: ThaiCashRounding})
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		// Cash is rounded down to the nearest 0.25, the card pays to the satang
+		if options[0].Payable != 100 || options[1].Payable != 100.1 {
+			t.Errorf("Expected 100 in cash and 100.10 by card, got %+v", options)
+		}
+	})
+	t.Run("Invalid BIN", func(t *testing.T) {
+		order := copyOrder(order)
+		order.Payment = Payment{Method: PayCard, BIN: "4045"}
+		if err := order.Validate(); err == nil {
+			t.Errorf("Expected error for a BIN of 4 digits")
+		}
+	})
+}
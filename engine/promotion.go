@@ -0,0 +1,508 @@
+// Package engine prices orders with promotions. The altpromotions command is built on it and teams can import it
+// to price orders in their own services or to add promotions of their own with RegisterRule
+package engine
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type Order struct {
+	ID         string      // Order ID
+	CustomerID string      // Customer of the order, empty for walk-in customers
+	CreatedAt  time.Time   // When the order was placed
+	Items      []Item      // List of items in the order
+	Promotions []Promotion // List of available promotions
+	Total      float64     // Total price of the order
+	Discount   float64     // Total discount of the order
+	State      OrderState  // Lifecycle state, empty is a draft
+	Refunded   float64     // Amount refunded after payment
+
+	Adjustments  []Adjustment        // Manual price overrides and discounts given by staff, priced with an AdjustmentPolicy
+	Context      SalesContext        // Channel, store, region and delivery zone the order is sold in
+	History      HistoryProvider     `json:"-"` // Looked up for promotions with a customer condition, not stored with the order
+	ReferralCode string              // Referral code accepted by ReferralProgram.Accept, empty without one
+	Referrals    ReferralStore       `json:"-"` // Where the ReferralCode was accepted, looked up for promotions with a referral condition
+	Payment      Payment             // How the order is paid, empty until the customer chooses
+	Rounding     float64             // Cash rounding of the payable, see RoundingPolicy
+	Applied      PromotionResult     // Promotion applied by the last pricing, zero when none gave a discount
+	Given        []AppliedAdjustment // Adjustments given by the last pricing with their value, empty when the promotion was given instead
+	Breakdown    []PromotionResult   // Discount or reason of every promotion at the last pricing
+	Locale       Locale              // Language of the receipts of the order, ReceiptOptions.Locale overrides it per receipt
+	Observer     PricingObserver     `json:"-"` // Told about every pricing of the order, like Metrics, not stored with the order
+}
+
+//Please note that item C isn't "Added" but discount is included for item C. The promotion isn't valid if item C isn't present.
+// There should also be two seperate items of A and B. Two of A doesn't satisfy the condition of this promotion.
+// Items sold by weight or volume have a Unit and the Price is per Unit. Their Quantity is used instead of Amount.
+type Item struct {
+	SKU               string
+	Price             float64
+	Amount            int64
+	Unit              Unit    // Unit of measure of the Price, empty for items sold per piece
+	Quantity          float64 // Quantity in Unit for weighted items, like 0.75 kg
+	Chosen            bool    // Chosen by the customer as the free or discounted unit, for promotions with SelectCustomerChosen
+	ValidSelectedItem bool    // For determining if the particular item is applicable for Buy A,B get C added for free,
+	ValidFreeItem     bool    // For determining if this particular item can be added to order for free in the promotion
+	ValidFiftyOff     bool    // For determining if this particular SKU is selected for 50% off
+}
+
+// This design relies on PromID calling the methods of the Promotion struct. PromIDs are like the voucher codes used in the store.
+// If Certain PromID's are included in the Object, the methods will be carried out when calculating The maximum discount
+// Inorder to keep it efficient, Only PromID's that are applied to the specific Order should be included.
+// Cap limits the discount of the promotion so the same PromID can be run with different configurations. 0 uses the default of the promotion.
+// Percent is the percentage off for promotions that are configured with one, like WPCT. Value is the Baht off for BAHT
+// Selection decides which unit is given away or discounted by promotions that discount a single unit
+// ValidFrom and ValidUntil are the validity window of the promotion, a zero time leaves that side of the window open
+// Scope limits the promotion to channels, stores, regions and delivery zones of the SalesContext of the order
+// Customer limits the promotion to customers with a certain history, like their first order
+// Payment limits the promotion to payment methods, like the cards of a partner bank
+// Names and Descriptions are the localised name and description shown on receipts, PromName is used for locales without a name
+type Promotion struct {
+	PromName   string
+	PromID     string
+	Cap        float64
+	Percent    float64
+	Selection  Selection
+	ValidFrom  time.Time
+	ValidUntil time.Time
+	Scope      Scope
+	Customer   CustomerCondition
+	Payment    PaymentCondition
+	Value      float64
+
+	Names        map[Locale]string
+	Descriptions map[Locale]string
+}
+
+// Items are validated before the total is calculated, the total isn't changed when an item is invalid
+// Pricing is frozen once the order is locked so CalcTotal and CalcDiscount return ErrOrderFrozen and keep the locked total and discount
+func (order *Order) CalcTotal() error {
+	if order.Frozen() {
+		return ErrOrderFrozen
+	}
+	if err := ValidateItems(order.Items); err != nil {
+		return err
+	}
+	var total float64 = 0
+	for _, item := range order.Items {
+		total += item.Price * item.Qty()
+	}
+	order.Total = total
+	return nil
+}
+
+// Total needs to be calculated before calling this function because methods need order.Total to compute the discount
+// In the Edge case of two promotions having the same discount, the left will be chosen which means order.Discount will not be changed
+// Invalid orders are rejected before any promotion is applied. Promotions are applied on the normalized lines, see Normalize
+func (order *Order) CalcDiscount() error {
+	if order.Frozen() {
+		return ErrOrderFrozen
+	}
+	if err := order.Validate(); err != nil {
+		return err
+	}
+	// Guard cases where there are 0 items in which case there is always no discount
+	if len(order.Promotions) <= 0 || len(order.Items) == 0 {
+		order.Discount = 0
+		return nil
+	}
+	normalized := order.normalized()
+	history := &customerHistory{}
+	for i := 0; i < len(order.Promotions); i++ {
+		order.Discount = Max(order.Discount, order.Promotions[i].apply(normalized, history))
+	}
+	return nil
+}
+
+// PromotionResult is the discount a single promotion would give on an order
+// Reason is why the promotion didn't apply when the order isn't eligible for it, or why its discount was limited
+type PromotionResult struct {
+	PromID   string
+	PromName string
+	Discount float64
+	Reason   string `json:",omitempty"`
+}
+
+// Apply dispatches the PromID to the method of the promotion and limits it to the Cap. Unknown PromIDs give no discount.
+// Promotions that the order isn't eligible for give no discount, see Eligible
+func (prom Promotion) Apply(order Order) float64 {
+	return prom.apply(order, &customerHistory{})
+}
+
+func (prom Promotion) apply(order Order, cache *customerHistory) float64 {
+	if ok, _ := prom.eligible(order, cache); !ok {
+		return 0
+	}
+	discount := prom.discount(order)
+	if prom.Cap > 0 && discount > prom.Cap {
+		return prom.Cap
+	}
+	return discount
+}
+
+// promotionRules maps every PromID to the method of the Promotion struct that calculates its discount
+// More promotion should be added in this map to calculate the discount, promotions of other packages are added with RegisterRule
+var promotionRules = map[string]func(Promotion, Order) float64{
+	"B2G1": Promotion.Buy2Get1Free,
+	"HOFF": Promotion.C50Off,
+	"B1N1": Promotion.Buy1N1B,
+	"D100": Promotion.C100Baht,
+	"B2I1": Promotion.BuyABFreeC,
+	"B1NH": Promotion.Buy1NextHalf,
+	"INCD": Promotion.DInc30,
+	"WPCT": Promotion.WeightedPercentOff,
+	"PCTO": Promotion.PercentOff,
+	"BAHT": Promotion.BahtOff,
+}
+
+var rulesMu sync.RWMutex
+
+// RegisterRule adds the rule that calculates the discount of promotions with the PromID, for promotions written outside this
+// package. Rules are usually registered from an init function. It panics when the PromID already has a rule,
+// so two packages can't silently replace each other's rule
+func RegisterRule(promID string, rule func(Promotion, Order) float64) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	if promID == "" || rule == nil {
+		panic("engine: RegisterRule needs a PromID and a rule")
+	}
+	if _, ok := promotionRules[promID]; ok {
+		panic("engine: RegisterRule called twice for " + promID)
+	}
+	promotionRules[promID] = rule
+}
+
+func lookupRule(promID string) (func(Promotion, Order) float64, bool) {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+	rule, ok := promotionRules[promID]
+	return rule, ok
+}
+
+func (prom Promotion) discount(order Order) float64 {
+	if rule, ok := lookupRule(prom.PromID); ok {
+		return rule(prom, order)
+	}
+	return 0
+}
+
+// Evaluate returns the discount of every promotion in the order without choosing one, in the order the promotions are listed
+// Total needs to be calculated before calling this function, same as CalcDiscount
+func (order *Order) Evaluate() []PromotionResult {
+	return order.evaluate(nil)
+}
+
+// evaluate tells the observer about every promotion and how long it took, Price observes the evaluation of the order.
+// The history of the customer is looked up once for all the promotions
+func (order *Order) evaluate(observer PricingObserver) []PromotionResult {
+	results := make([]PromotionResult, 0, len(order.Promotions))
+	normalized := order.normalized()
+	history := &customerHistory{}
+	for _, prom := range order.Promotions {
+		start := time.Now()
+		result := prom.result(normalized, history)
+		if observer != nil {
+			observer.PromotionEvaluated(order, result, time.Since(start))
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// result is the discount of the promotion on the normalized order, or the reason the order isn't eligible for it
+func (prom Promotion) result(normalized Order, history *customerHistory) PromotionResult {
+	result := PromotionResult{PromID: prom.PromID, PromName: prom.PromName}
+	if ok, reason := prom.eligible(normalized, history); !ok {
+		result.Reason = reason
+	} else if len(normalized.Items) > 0 {
+		result.Discount = prom.apply(normalized, history)
+	}
+	return result
+}
+
+// Best picks the promotion that CalcDiscount would apply. The left is chosen when two promotions have the same discount
+// and false is returned when no promotion gives a discount
+func Best(results []PromotionResult) (PromotionResult, bool) {
+	var best PromotionResult
+	for _, result := range results {
+		if result.Discount > best.Discount {
+			best = result
+		}
+	}
+	return best, best.Discount > 0
+}
+
+// PricingResult is the outcome of pricing an order with the promotions that were considered.
+// Experiment and Variant are set when the promotions were chosen by an experiment so outcomes can be attributed to the variant
+type PricingResult struct {
+	OrderID   string
+	Total     float64
+	Discount  float64
+	Applied   PromotionResult // Zero when no promotion gives a discount
+	Breakdown []PromotionResult
+	Lines     []NormalizedLine // The lines the promotions were applied on with the original lines of each
+	// Adjustments are the manual adjustments that were given and ManualDiscount is the part of Discount they gave
+	Adjustments    []AppliedAdjustment
+	ManualDiscount float64
+	Experiment     string
+	Variant        string
+	Rounding       float64 // Rounding of the payable as its own line, negative when it was rounded down
+}
+
+// Payable is what the customer pays
+func (result PricingResult) Payable() float64 {
+	return result.Total - result.Discount + result.Rounding
+}
+
+// Payable is what the customer pays, the Rounding is only set when the order was priced with a RoundingPolicy
+func (order *Order) Payable() float64 {
+	return order.Total - order.Discount + order.Rounding
+}
+
+// Price calculates the total and the discount of the order from scratch and returns the result with the discount of every promotion.
+// It is priced with the zero PricingPolicy, so orders with manual adjustments are rejected, see PricingPolicy
+func (order *Order) Price() (PricingResult, error) {
+	return PricingPolicy{}.Price(order)
+}
+
+// PricingPolicy is what an order is priced with besides its items and promotions. Every way of pricing an order goes through
+// its Price, so adjustments, budgets and rounding always combine with the promotions the same way.
+// The zero policy has no adjustment Limits, so orders with adjustments need a policy that allows them
+type PricingPolicy struct {
+	Adjustments AdjustmentPolicy
+	Budgets     BudgetLedger   `json:"-"` // Optional, without it promotions have no budget
+	Rounding    RoundingPolicy // The zero RoundingPolicy doesn't round
+}
+
+// Price prices the order in one pass: promotions are evaluated on the original prices of the lines and limited to their
+// budgets, the best one is combined with the adjustments by their Interaction and the discount is set on the order.
+// Last the payable is rounded for the payment of the order. The difference is its own Rounding line on the result and the
+// order instead of being added to the discount, so the discount still reconciles with the promotions.
+// The Observer of the order is told about every promotion that was evaluated and about the result or the error
+func (policy PricingPolicy) Price(order *Order) (PricingResult, error) {
+	start := time.Now()
+	result, err := policy.price(order)
+	if order.Observer != nil {
+		order.Observer.OrderPriced(order, result, err, time.Since(start))
+	}
+	return result, err
+}
+
+func (policy PricingPolicy) price(order *Order) (PricingResult, error) {
+	if order.Frozen() {
+		return PricingResult{}, ErrOrderFrozen
+	}
+	if err := order.Validate(); err != nil {
+		return PricingResult{}, err
+	}
+	if err := policy.validate(*order); err != nil {
+		return PricingResult{}, err
+	}
+	order.Discount = 0
+	order.Rounding = 0
+	order.CalcTotal()
+	return policy.settle(order, order.evaluate(order.Observer))
+}
+
+// validate rejects adjustments that are not authorised and unknown roundings before anything is priced
+func (policy PricingPolicy) validate(order Order) error {
+	if err := policy.Adjustments.Validate(order); err != nil {
+		return err
+	}
+	if errs := policy.Rounding.validate(); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// settle prices the order from the discount of every promotion on its Total. The Cart settles the results it keeps
+// up to date on every scan the same way Price settles the promotions it evaluates
+func (policy PricingPolicy) settle(order *Order, breakdown []PromotionResult) (PricingResult, error) {
+	if policy.Budgets != nil {
+		breakdown = limitToBudget(policy.Budgets, breakdown)
+	}
+	result := PricingResult{OrderID: order.ID, Total: order.Total, Breakdown: breakdown, Lines: Normalize(order.Items)}
+	result.Applied, _ = Best(result.Breakdown)
+	result.Discount = result.Applied.Discount
+	if err := policy.Adjustments.combine(*order, &result); err != nil {
+		return PricingResult{}, err
+	}
+	_, result.Rounding = policy.Rounding.For(order.Payment.Method).Round(result.Payable())
+	order.Discount = result.Discount
+	order.Rounding = result.Rounding
+	order.Applied, order.Breakdown, order.Given = result.Applied, result.Breakdown, result.Adjustments
+	return result, nil
+}
+
+// Basic Max comparison function for making the code easier to read
+func Max(leftN, rightN float64) float64 {
+	if leftN == rightN {
+		return leftN
+	}
+	if leftN > rightN {
+		return leftN
+	}
+	return rightN
+}
+func (order *Order) Print() {
+	locale := order.Locale
+	fmt.Printf("%s: %s\n", locale.Label("Order ID"), order.ID)
+	fmt.Printf("%s: %s\n", locale.Label("Total"), locale.Money(order.Total))
+	fmt.Printf("%s: %s\n", locale.Label("Discount"), locale.Money(order.Discount))
+	if order.Rounding != 0 {
+		fmt.Printf("%s: %s\n", locale.Label("Rounding"), locale.Money(order.Rounding))
+	}
+	fmt.Printf("%s: %s\n", locale.Label("Total Payable"), locale.Money(order.Payable()))
+}
+
+// This implementation needs a minimum of 3 amounts of a particular item to take into effect. The discount will be equal to one item's price.
+// This function addresses edge case of two items in the order with buy2get1free with amount greater than 3. The Selection of the promotion decides which item is free,
+// by default the higher item with bigger price is chosen for buy2get1free
+// Weighted items can't be counted in units so they are not applicable
+func (prom Promotion) Buy2Get1Free(Order Order) float64 {
+	free, ok := prom.selectItem(Order.Items, buy2Get1Qualifies)
+	if !ok {
+		return 0
+	}
+	return free.Price
+}
+
+func buy2Get1Qualifies(item Item) bool {
+	return !item.Weighted() && item.Amount >= 3
+}
+
+// Since multiplication is faster than division
+func (prom Promotion) C50Off(Order Order) float64 {
+	return Order.Total * 0.5
+}
+
+// Buy 1 Next item at 1 Baht is only applicable for same item. It prevents misuse in practical cases like people buying a cheap item to get another at a huge price
+// Weighted items are not applicable since there is no next unit. Items cheaper than 1 Baht don't get a discount
+func (prom Promotion) Buy1N1B(Order Order) float64 {
+	next, ok := prom.selectItem(Order.Items, buy1N1BQualifies)
+	if !ok || next.Price < 1 {
+		return 0
+	}
+	return next.Price - 1
+}
+
+func buy1N1BQualifies(item Item) bool {
+	return !item.Weighted() && item.Amount > 1
+}
+func (prom Promotion) C100Baht(Order Order) float64 {
+	if Order.Total >= 1000 {
+		return 100
+	} else {
+		return 0
+	}
+}
+
+// The FreeItem picked by the Selection of the promotion is added as Discount, by default the highest. A weighted item can be selected but can't be the free item since its Price is per Unit
+// The first Loop checks if the items that are selected for this particular promotion is greater than two
+func (prom Promotion) BuyABFreeC(Order Order) float64 {
+	if len(Order.Items) < 2 {
+		return 0
+	}
+	var SelectedItems float64 = 0
+	for i := 0; i < len(Order.Items); i++ {
+		if Order.Items[i].ValidSelectedItem {
+			SelectedItems++
+		}
+	}
+	if SelectedItems < 2 {
+		return 0
+	}
+	free, ok := prom.selectItem(Order.Items, freeItemQualifies)
+	if !ok {
+		return 0
+	}
+	return free.Price
+}
+
+func freeItemQualifies(item Item) bool {
+	return item.ValidFreeItem && !item.Weighted()
+}
+
+// Only one of the items that are applicable to being 50% off gets the Half price, by default the greatest item prioritizing high discount
+// Weighted items are not applicable, same as the free item of BuyABFreeC
+func (prom Promotion) Buy1NextHalf(Order Order) float64 {
+	if len(Order.Items) < 2 {
+		return 0
+	}
+	half, ok := prom.selectItem(Order.Items, fiftyOffQualifies)
+	if !ok {
+		return 0
+	}
+	return half.Price * 0.5
+}
+
+func fiftyOffQualifies(item Item) bool {
+	return item.ValidFiftyOff && !item.Weighted()
+}
+
+// DInc30 expanded is Discount increment till 30. If there are 3 or more items then the discount is 30% of the total.
+// The discount is limited to 1000 unless the promotion has its own Cap
+// A weighted item counts as one item whatever its weight
+func (prom Promotion) DInc30(Order Order) float64 {
+	var totalItems int64 = 0
+	for i := 0; i < len(Order.Items); i++ {
+		if Order.Items[i].Weighted() {
+			totalItems++
+		} else {
+			totalItems += Order.Items[i].Amount
+		}
+	}
+	var discount float64
+	switch {
+	case totalItems == 1:
+		{
+			discount = Order.Total * 0.15
+			break
+		}
+	case totalItems == 2:
+		{
+			discount = Order.Total * 0.2
+			break
+		}
+	case totalItems >= 3:
+		{
+			discount = Order.Total * 0.3
+			break
+		}
+	}
+	var limit float64 = 1000
+	if prom.Cap > 0 {
+		limit = prom.Cap
+	}
+	if discount >= limit {
+		return limit
+	} else {
+		return discount
+	}
+}
+
+// WeightedPercentOff is Percent off every weighted item, like 20% off per kg of produce. Items sold per piece are not applicable
+func (prom Promotion) WeightedPercentOff(Order Order) float64 {
+	var discount float64 = 0
+	for i := 0; i < len(Order.Items); i++ {
+		if Order.Items[i].Weighted() {
+			discount += Order.Items[i].Price * Order.Items[i].Quantity * prom.Percent / 100
+		}
+	}
+	return discount
+}
+
+// PercentOff is Percent off the whole order, like 20% off the first order
+func (prom Promotion) PercentOff(Order Order) float64 {
+	return Order.Total * prom.Percent / 100
+}
+
+// BahtOff is Value Baht off the order without a minimum total. The discount can't be more than the total
+func (prom Promotion) BahtOff(Order Order) float64 {
+	if prom.Value > Order.Total {
+		return Order.Total
+	}
+	return prom.Value
+}
@@ -1,4 +1,4 @@
-package main
+package engine
 
 import (
 	"testing"
@@ -560,3 +560,19 @@ func TestMultiplePromotions(t *testing.T) {
 		}
 	})
 }
+
+func TestRegisterRule(t *testing.T) {
+	expectPanic := func(name string, register func()) {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected RegisterRule to panic")
+				}
+			}()
+			register()
+		})
+	}
+	// Registering a rule for a PromID that has one would replace the rule of every order priced with it
+	expectPanic("PromID that has a rule", func() { RegisterRule("B2G1", Promotion.C50Off) })
+	expectPanic("Without a rule", func() { RegisterRule("NONE", nil) })
+}
@@ -0,0 +1,368 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Receipt is everything a receipt shows, built once from a priced order so every renderer shows the same numbers
+type Receipt struct {
+	OrderID    string
+	Time       time.Time
+	Lines      []ReceiptLine
+	Promotions []ReceiptDiscount // The applied promotion with its PromName
+	Manual     []ReceiptDiscount // Manual adjustments with their reason
+	Subtotal   float64
+	Discount   float64
+	Rounding   float64
+	Total      float64 // What the customer pays
+	TaxRate    float64
+	Tax        float64 // VAT included in the Total
+	Savings    float64
+	Locale     Locale // Language and formatting of the rendered receipt
+}
+
+// ReceiptLine is a line of Order.Items, Quantity is the Amount or the weight of weighted items
+type ReceiptLine struct {
+	SKU       string
+	Quantity  float64
+	Unit      Unit
+	UnitPrice float64
+	Amount    float64
+}
+
+// ReceiptDiscount is a discount line with the name shown on the receipt. Promotions have their localised description
+type ReceiptDiscount struct {
+	Name        string
+	Description string `json:",omitempty"`
+	Amount      float64
+}
+
+// ReceiptOptions are the settings of the store. TaxRate is the VAT in percent included in the prices, like 7 in Thailand.
+// Locale is the language of this receipt, like an English copy of a Thai order, the Locale of the order when empty
+type ReceiptOptions struct {
+	TaxRate float64
+	Locale  Locale
+}
+
+// NewReceipt builds the receipt of the order from its pricing result. Promotion lines use the name of the promotion in the locale
+func NewReceipt(order Order, result PricingResult, opts ReceiptOptions) Receipt {
+	locale := opts.Locale
+	if locale == LocalePlain {
+		locale = order.Locale
+	}
+	receipt := Receipt{
+		OrderID:  order.ID,
+		Time:     order.CreatedAt,
+		Subtotal: result.Total,
+		Discount: result.Discount,
+		Rounding: result.Rounding,
+		Total:    result.Payable(),
+		TaxRate:  opts.TaxRate,
+		Savings:  result.Discount,
+		Locale:   locale,
+	}
+	// Receipts of orders without CreatedAt are dated when they are printed
+	if receipt.Time.IsZero() {
+		receipt.Time = time.Now()
+	}
+	for _, item := range order.Items {
+		receipt.Lines = append(receipt.Lines, ReceiptLine{SKU: item.SKU, Quantity: item.Qty(), Unit: item.Unit, UnitPrice: item.Price, Amount: item.Price * item.Qty()})
+	}
+	if promotion := result.Discount - result.ManualDiscount; result.Applied.PromID != "" && promotion > 0 {
+		line := ReceiptDiscount{Name: result.Applied.PromName, Amount: promotion}
+		for _, prom := range order.Promotions {
+			if prom.PromID == result.Applied.PromID && prom.PromName == result.Applied.PromName {
+				line.Name, line.Description = prom.Name(locale), prom.Description(locale)
+				break
+			}
+		}
+		receipt.Promotions = append(receipt.Promotions, line)
+	}
+	if result.ManualDiscount > 0 {
+		for _, adj := range result.Adjustments {
+			receipt.Manual = append(receipt.Manual, ReceiptDiscount{Name: adj.Reason, Amount: adj.Value})
+		}
+	}
+	if opts.TaxRate > 0 {
+		receipt.Tax = math.Round(receipt.Total*opts.TaxRate/(100+opts.TaxRate)*100) / 100
+	}
+	return receipt
+}
+
+// ReceiptRenderer writes a receipt in a format
+type ReceiptRenderer interface {
+	Render(w io.Writer, receipt Receipt) error
+}
+
+// receiptRenderers maps the formats to their renderer, more formats can be added to this map
+var receiptRenderers = map[string]ReceiptRenderer{
+	"text":   TextRenderer{Width: 40},
+	"json":   JSONRenderer{},
+	"html":   HTMLRenderer{},
+	"escpos": ESCPOSRenderer{Width: 42, Cut: true},
+}
+
+// ReceiptFormats returns the formats that can be rendered, sorted
+func ReceiptFormats() []string {
+	formats := make([]string, 0, len(receiptRenderers))
+	for format := range receiptRenderers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// RenderReceipt writes the receipt with the renderer of the format
+func RenderReceipt(w io.Writer, format string, receipt Receipt) error {
+	renderer, ok := receiptRenderers[format]
+	if !ok {
+		return fmt.Errorf("unknown receipt format %q, use one of %s", format, strings.Join(ReceiptFormats(), ", "))
+	}
+	return renderer.Render(w, receipt)
+}
+
+// receiptRow is a row of the text layouts, an empty Right is a row with only a label. Bold is the total
+type receiptRow struct {
+	Left  string
+	Right string
+	Bold  bool
+}
+
+// rows lays out the receipt as label and amount rows, shared by the text and ESC/POS renderers
+func (receipt Receipt) rows() (header, lines, totals []receiptRow) {
+	locale := receipt.Locale
+	header = []receiptRow{
+		{Left: locale.Label("Order") + " " + receipt.OrderID},
+		{Left: locale.Date(receipt.Time)},
+	}
+	for _, line := range receipt.Lines {
+		lines = append(lines, receiptRow{Left: line.SKU, Right: locale.Money(line.Amount)})
+		if line.Unit != UnitEach {
+			lines = append(lines, receiptRow{Left: fmt.Sprintf("  %s %s x %s", locale.Number(line.Quantity, 3), line.Unit, locale.Money(line.UnitPrice))})
+		} else if line.Quantity != 1 {
+			lines = append(lines, receiptRow{Left: fmt.Sprintf("  %g x %s", line.Quantity, locale.Money(line.UnitPrice))})
+		}
+	}
+	totals = append(totals, receiptRow{Left: locale.Label("Subtotal"), Right: locale.Money(receipt.Subtotal)})
+	for _, prom := range receipt.Promotions {
+		totals = append(totals, receiptRow{Left: prom.Name, Right: locale.Money(-prom.Amount)})
+	}
+	for _, adj := range receipt.Manual {
+		totals = append(totals, receiptRow{Left: adj.Name, Right: locale.Money(-adj.Amount)})
+	}
+	if receipt.Rounding != 0 {
+		totals = append(totals, receiptRow{Left: locale.Label("Rounding"), Right: locale.Money(receipt.Rounding)})
+	}
+	totals = append(totals, receiptRow{Left: locale.Label("Total"), Right: locale.Money(receipt.Total), Bold: true})
+	if receipt.TaxRate > 0 {
+		totals = append(totals, receiptRow{Left: fmt.Sprintf(locale.Label("VAT %g%% included"), receipt.TaxRate), Right: locale.Money(receipt.Tax)})
+	}
+	if receipt.Savings > 0 {
+		totals = append(totals, receiptRow{Left: locale.Label("You saved"), Right: locale.Money(receipt.Savings)})
+	}
+	return header, lines, totals
+}
+
+// column puts the label on the left and the amount on the right of a line of width characters.
+// Labels that don't fit are cut so the amounts stay aligned
+func column(row receiptRow, width int) string {
+	space := width - displayWidth(row.Right) - 1
+	if row.Right == "" {
+		space = width
+	}
+	left := row.Left
+	if displayWidth(left) > space {
+		cut, used := 0, 0
+		for i, r := range left {
+			if !combining(r) {
+				if used == space {
+					break
+				}
+				used++
+			}
+			cut = i + utf8.RuneLen(r)
+		}
+		left = left[:cut]
+	}
+	if row.Right == "" {
+		return left
+	}
+	return left + strings.Repeat(" ", width-displayWidth(left)-displayWidth(row.Right)) + row.Right
+}
+
+// displayWidth counts the characters of s that take up a column. Thai vowels and tone marks above and below
+// a consonant are printed in its column
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		if !combining(r) {
+			width++
+		}
+	}
+	return width
+}
+
+func combining(r rune) bool {
+	return r == '\u0e31' || (r >= '\u0e34' && r <= '\u0e3a') || (r >= '\u0e47' && r <= '\u0e4e')
+}
+
+// TextRenderer writes a plain text receipt with the amounts in a column at Width characters
+type TextRenderer struct {
+	Width int
+}
+
+func (renderer TextRenderer) Render(w io.Writer, receipt Receipt) error {
+	width := renderer.Width
+	if width <= 0 {
+		width = 40
+	}
+	header, lines, totals := receipt.rows()
+	var b strings.Builder
+	for _, group := range [][]receiptRow{header, lines, totals} {
+		for _, row := range group {
+			b.WriteString(column(row, width))
+			b.WriteString("\n")
+		}
+		b.WriteString(strings.Repeat("-", width))
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// JSONRenderer writes the receipt as indented JSON
+type JSONRenderer struct{}
+
+func (JSONRenderer) Render(w io.Writer, receipt Receipt) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(receipt)
+}
+
+var receiptHTML = template.Must(template.New("receipt").Parse(`<!DOCTYPE html>
+<html{{with .Locale}} lang="{{.}}"{{end}}>
+<head><meta charset="utf-8"><title>{{.Locale.Label "Receipt"}} {{.OrderID}}</title></head>
+<body>
+<h1>{{.Locale.Label "Receipt"}}</h1>
+<p>{{.Locale.Label "Order"}} {{.OrderID}}<br>{{.Locale.Date .Time}}</p>
+<table>
+<tr><th>{{.Locale.Label "Item"}}</th><th>{{.Locale.Label "Quantity"}}</th><th>{{.Locale.Label "Price"}}</th><th>{{.Locale.Label "Amount"}}</th></tr>
+{{- range .Lines}}
+<tr><td>{{.SKU}}</td><td>{{.Quantity}}{{if .Unit}} {{.Unit}}{{end}}</td><td>{{$.Locale.Money .UnitPrice}}</td><td>{{$.Locale.Money .Amount}}</td></tr>
+{{- end}}
+<tr><td colspan="3">{{.Locale.Label "Subtotal"}}</td><td>{{.Locale.Money .Subtotal}}</td></tr>
+{{- range .Promotions}}
+<tr class="promotion"><td colspan="3">{{.Name}}{{with .Description}}<br><small>{{.}}</small>{{end}}</td><td>-{{$.Locale.Money .Amount}}</td></tr>
+{{- end}}
+{{- range .Manual}}
+<tr class="adjustment"><td colspan="3">{{.Name}}</td><td>-{{$.Locale.Money .Amount}}</td></tr>
+{{- end}}
+{{- if .Rounding}}
+<tr><td colspan="3">{{.Locale.Label "Rounding"}}</td><td>{{.Locale.Money .Rounding}}</td></tr>
+{{- end}}
+<tr class="total"><td colspan="3">{{.Locale.Label "Total"}}</td><td>{{.Locale.Money .Total}}</td></tr>
+{{- if .TaxRate}}
+<tr><td colspan="3">{{printf (.Locale.Label "VAT %g%% included") .TaxRate}}</td><td>{{.Locale.Money .Tax}}</td></tr>
+{{- end}}
+</table>
+{{- if .Savings}}
+<p>{{.Locale.Label "You saved"}} {{.Locale.Money .Savings}}</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// HTMLRenderer writes an HTML page for e-receipts. Names are escaped
+type HTMLRenderer struct{}
+
+func (HTMLRenderer) Render(w io.Writer, receipt Receipt) error {
+	return receiptHTML.Execute(w, receipt)
+}
+
+// ESC/POS commands of thermal receipt printers
+var (
+	escposInit       = []byte{0x1b, '@'}
+	escposCenter     = []byte{0x1b, 'a', 1}
+	escposLeft       = []byte{0x1b, 'a', 0}
+	escposBoldOn     = []byte{0x1b, 'E', 1}
+	escposBoldOff    = []byte{0x1b, 'E', 0}
+	escposFeedAndCut = []byte{0x1d, 'V', 'B', 3}
+)
+
+// escposThaiCodePage is the ESC t page of Epson printers for Thai, Thai character code 18
+const escposThaiCodePage = 26
+
+// ESCPOSRenderer writes the byte stream of a thermal printer with Width characters per line, 42 for 80 mm paper with font A.
+// The paper is cut at the end when Cut is set. Printers don't take UTF-8, the text is encoded in TIS-620 (the Thai half of CP874)
+// and CodePage is the ESC t page the printer has it at. Printer models number their pages differently, 0 uses the Epson page
+type ESCPOSRenderer struct {
+	Width    int
+	Cut      bool
+	CodePage byte
+}
+
+// escposText appends the text in TIS-620. Thai is U+0E01 to U+0E5B and is at 0xA1 to 0xFB, ASCII stays as it is
+// and characters the code page doesn't have are printed as ?
+func escposText(b []byte, text string) []byte {
+	for _, r := range text {
+		switch {
+		case r < 0x80:
+			b = append(b, byte(r))
+		case r >= '\u0e01' && r <= '\u0e5b':
+			b = append(b, byte(r-0x0e00+0xa0))
+		default:
+			b = append(b, '?')
+		}
+	}
+	return b
+}
+
+func (renderer ESCPOSRenderer) Render(w io.Writer, receipt Receipt) error {
+	width := renderer.Width
+	if width <= 0 {
+		width = 42
+	}
+	page := renderer.CodePage
+	if page == 0 {
+		page = escposThaiCodePage
+	}
+	header, lines, totals := receipt.rows()
+	var b []byte
+	b = append(b, escposInit...)
+	b = append(b, 0x1b, 't', page)
+	b = append(b, escposCenter...)
+	for _, row := range header {
+		b = escposText(b, row.Left)
+		b = append(b, '\n')
+	}
+	b = append(b, escposLeft...)
+	for _, row := range lines {
+		b = escposText(b, column(row, width))
+		b = append(b, '\n')
+	}
+	b = append(b, strings.Repeat("-", width)...)
+	b = append(b, '\n')
+	for _, row := range totals {
+		if row.Bold {
+			b = append(b, escposBoldOn...)
+		}
+		b = escposText(b, column(row, width))
+		b = append(b, '\n')
+		if row.Bold {
+			b = append(b, escposBoldOff...)
+		}
+	}
+	if renderer.Cut {
+		b = append(b, escposFeedAndCut...)
+	}
+	_, err := w.Write(b)
+	return err
+}
@@ -0,0 +1,132 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func receiptFixture() Receipt {
+	order := Order{ID: "R1", CreatedAt: time.Date(2024, 5, 10, 14, 30, 0, 0, time.UTC), Items: []Item{
+		{SKU: "A", Price: 100, Amount: 3, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		{SKU: "MANGO", Price: 80, Unit: UnitKg, Quantity: 1.25, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+	}, Promotions: []Promotion{{PromName: "Buy 2 <get> 1 free", PromID: "B2G1"}}, Payment: Payment{Method: PayCash}}
+	result, _ := ThaiCashRounding.Price(&order)
+	return NewReceipt(order, result, ReceiptOptions{TaxRate: 7})
+}
+
+func TestReceipt(t *testing.T) {
+	receipt := receiptFixture()
+	// 400 total, 100 off, 300 payable of which 19.63 is VAT
+	if receipt.Subtotal != 400 || receipt.Total != 300 || receipt.Tax != 19.63 || receipt.Savings != 100 {
+		t.Errorf("Expected 400, 300, 19.63 VAT and 100 saved, got %+v", receipt)
+	}
+	if len(receipt.Promotions) != 1 || receipt.Promotions[0].Name != "Buy 2 <get> 1 free" {
+		t.Errorf("Expected the promotion line with its PromName, got %+v", receipt.Promotions)
+	}
+
+	t.Run("Text", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := RenderReceipt(&buf, "text", receipt); err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if len(line) > 40 {
+				t.Errorf("Expected lines of at most 40 characters, got %q", line)
+			}
+		}
+		if !strings.Contains(buf.String(), "Total"+strings.Repeat(" ", 29)+"300.00") {
+			t.Errorf("Expected the total aligned to the right, got\n%s", buf.String())
+		}
+	})
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		RenderReceipt(&buf, "json", receipt)
+		var decoded Receipt
+		if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || decoded.Total != 300 || len(decoded.Lines) != 2 {
+			t.Errorf("Expected the receipt back, got %+v and %v", decoded, err)
+		}
+	})
+	t.Run("HTML escapes names", func(t *testing.T) {
+		var buf bytes.Buffer
+		RenderReceipt(&buf, "html", receipt)
+		if !strings.Contains(buf.String(), "Buy 2 &lt;get&gt; 1 free") || !strings.Contains(buf.String(), "300.00") {
+			t.Errorf("Expected the escaped promotion name and total, got\n%s", buf.String())
+		}
+	})
+	t.Run("ESC/POS", func(t *testing.T) {
+		var buf bytes.Buffer
+		RenderReceipt(&buf, "escpos", receipt)
+		out := buf.Bytes()
+		if !bytes.HasPrefix(out, escposInit) || !bytes.HasSuffix(out, escposFeedAndCut) {
+			t.Errorf("Expected the stream to start with init and end with a cut, got %q", out)
+		}
+		if !bytes.Contains(out, append(escposBoldOn, []byte("Total")...)) {
+			t.Errorf("Expected the total in bold, got %q", out)
+		}
+	})
+	t.Run("Unknown format", func(t *testing.T) {
+		if err := RenderReceipt(&bytes.Buffer{}, "pdf", receipt); err == nil {
+			t.Errorf("Expected error for unknown format")
+		}
+	})
+}
+
+func TestLocalisedReceipt(t *testing.T) {
+	order := Order{ID: "R2", Locale: LocaleThai, CreatedAt: time.Date(2024, 5, 10, 7, 30, 0, 0, time.UTC), Items: []Item{
+		{SKU: "A", Price: 1000, Amount: 3, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+	}, Promotions: []Promotion{{PromName: "Buy 2 get 1 free", PromID: "B2G1",
+		Names:        map[Locale]string{LocaleThai: "ซื้อ 2 แถม 1", LocaleEnglish: "Buy 2, get 1 free"},
+		Descriptions: map[Locale]string{LocaleThai: "รับฟรี 1 ชิ้น"}}}}
+	result, _ := order.Price()
+
+	receipt := NewReceipt(order, result, ReceiptOptions{TaxRate: 7})
+	if receipt.Locale != LocaleThai || receipt.Promotions[0].Name != "ซื้อ 2 แถม 1" || receipt.Promotions[0].Description != "รับฟรี 1 ชิ้น" {
+		t.Errorf("Expected the Thai promotion line of the order locale, got %+v", receipt.Promotions)
+	}
+	var buf bytes.Buffer
+	RenderReceipt(&buf, "text", receipt)
+	for _, want := range []string{"10 พ.ค. 2567 14:30", "฿3,000.00", "-฿1,000.00", "รวมภาษีมูลค่าเพิ่ม 7%"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %s on the Thai receipt, got\n%s", want, buf.String())
+		}
+	}
+	// Tone marks and vowels above the consonants don't take a column so the amounts stay aligned
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if displayWidth(line) > 40 {
+			t.Errorf("Expected lines of at most 40 columns, got %q", line)
+		}
+	}
+	if want := "ซื้อ 2 แถม 1" + strings.Repeat(" ", 40-10-10) + "-฿1,000.00"; !strings.Contains(buf.String(), want) {
+		t.Errorf("Expected %q, got\n%s", want, buf.String())
+	}
+
+	t.Run("ESC/POS in the Thai code page", func(t *testing.T) {
+		var buf bytes.Buffer
+		RenderReceipt(&buf, "escpos", receipt)
+		out := buf.Bytes()
+		if !bytes.HasPrefix(out, append(append([]byte(nil), escposInit...), 0x1b, 't', escposThaiCodePage)) {
+			t.Errorf("Expected the code page to be selected after init, got %q", out[:5])
+		}
+		// ยอดรวม and ฿ in TIS-620
+		total := []byte{0xc2, 0xcd, 0xb4, 0xc3, 0xc7, 0xc1}
+		if !bytes.Contains(out, total) || !bytes.Contains(out, append([]byte{0xdf}, "3,000.00"...)) {
+			t.Errorf("Expected the total in TIS-620, got %q", out)
+		}
+		if bytes.Contains(out, []byte("ยอดรวม")) {
+			t.Errorf("Expected no UTF-8 in the stream, got %q", out)
+		}
+	})
+	t.Run("Locale per receipt", func(t *testing.T) {
+		receipt := NewReceipt(order, result, ReceiptOptions{Locale: LocaleEnglish})
+		var buf bytes.Buffer
+		RenderReceipt(&buf, "html", receipt)
+		for _, want := range []string{`lang="en"`, "Buy 2, get 1 free", "10 May 2024 14:30", "฿2,000.00"} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("Expected %s on the English receipt, got\n%s", want, buf.String())
+			}
+		}
+	})
+}
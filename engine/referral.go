@@ -0,0 +1,349 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Referrals reward both customers: the new customer enters the referral code of an existing customer and gets the promotions
+// with CustomerCondition.Referred on their first order, the referrer is rewarded once that order is paid and can no longer be returned.
+//
+//	program := NewReferralProgram(store, Promotion{PromName: "Thanks for referring", PromID: "BAHT", Value: 100}, 0)
+//	lifecycle.Subscribe(program.OnOrderEvent)
+//	program.Accept(&order, "SOMCHAI10")
+//	...
+//	program.IssueRewards(time.Now()) // Run daily, rewards are emitted as ReferralRewarded events
+
+type ReferralStatus string
+
+const (
+	ReferralPending  ReferralStatus = "pending"  // Accepted on an order that isn't paid yet
+	ReferralPaid     ReferralStatus = "paid"     // Paid, waiting for the return window to pass
+	ReferralRewarded ReferralStatus = "rewarded" // The referrer got the reward
+	ReferralVoid     ReferralStatus = "void"     // The order was cancelled or refunded
+)
+
+// Referral is the use of a referral code on the first order of a new customer
+type Referral struct {
+	Code       string
+	ReferrerID string
+	RefereeID  string
+	OrderID    string
+	Household  string
+	Status     ReferralStatus
+	AcceptedAt time.Time
+	PaidAt     time.Time
+}
+
+// ReferralStore keeps the referral codes of customers and the referrals made with them.
+// Add must run the check and save the referral atomically, so two orders can't both pass the limits of the fraud rules.
+// Transition must compare and set the status atomically, so a referrer is rewarded once when IssueRewards runs twice at the same time
+type ReferralStore interface {
+	CodeOwner(code string) (customerID string, err error) // ErrUnknownReferralCode when no customer has the code
+	Referrals() ([]Referral, error)
+	Referral(orderID string) (referral Referral, ok bool, err error)         // ok is false when no referral was accepted for the order
+	Add(referral Referral, check func(referrals []Referral) error) error     // Saves the referral when check accepts the referrals in the store
+	Save(referral Referral) error                                            // Adds the referral or replaces the referral of the same order
+	Transition(orderID string, from, to ReferralStatus) (ok bool, err error) // Changes the status of the referral, ok is false when it was no longer from
+}
+
+var (
+	ErrUnknownReferralCode = errors.New("unknown referral code")
+	ErrReferralRejected    = errors.New("referral rejected")
+	ErrNoReferralHistory   = errors.New("referral needs the history of customers, set the History of the program or the order")
+)
+
+// ReferralEvent is emitted when a referral is accepted, rewarded or voided. Reward is only set on ReferralRewarded
+type ReferralEvent struct {
+	Status   ReferralStatus
+	Referral Referral
+	Reward   Promotion
+	Points   int
+	Time     time.Time
+}
+
+// ReferralProgram checks referral codes against the fraud rules and issues the rewards of referrers.
+// Reward is the voucher the referrer gets for their next order and Points the loyalty points, either can be empty.
+// HouseholdLimit is how many customers of the same household can be referred, 0 is no limit
+type ReferralProgram struct {
+	Store          ReferralStore
+	History        HistoryProvider // Used for the household and order count of the new customer, the history of the order when nil
+	Reward         Promotion
+	Points         int
+	ReturnWindow   time.Duration
+	HouseholdLimit int
+	Now            func() time.Time
+
+	mu          sync.Mutex
+	subscribers []func(ReferralEvent)
+}
+
+// NewReferralProgram returns a program with a return window of 14 days when returnWindow is 0
+func NewReferralProgram(store ReferralStore, reward Promotion, returnWindow time.Duration) *ReferralProgram {
+	if returnWindow == 0 {
+		returnWindow = 14 * 24 * time.Hour
+	}
+	return &ReferralProgram{Store: store, Reward: reward, ReturnWindow: returnWindow, Now: time.Now}
+}
+
+// Subscribe registers a function that is called for every referral event
+func (program *ReferralProgram) Subscribe(fn func(ReferralEvent)) {
+	program.mu.Lock()
+	defer program.mu.Unlock()
+	program.subscribers = append(program.subscribers, fn)
+}
+
+func (program *ReferralProgram) emit(event ReferralEvent) {
+	program.mu.Lock()
+	subscribers := program.subscribers
+	program.mu.Unlock()
+	for _, fn := range subscribers {
+		fn(event)
+	}
+}
+
+// Accept checks the code for the order and sets it as the ReferralCode of the order with the Store as the Referrals it is checked in.
+// The error wraps ErrReferralRejected
+// when a fraud rule rejects it: the code is the customer's own or of someone in the same household, the customer was already
+// referred or has ordered before, or the household reached the HouseholdLimit
+func (program *ReferralProgram) Accept(order *Order, code string) error {
+	if order.Frozen() {
+		return ErrOrderFrozen
+	}
+	reject := func(reason string) error {
+		return fmt.Errorf("%w: %s", ErrReferralRejected, reason)
+	}
+	if order.CustomerID == "" {
+		return reject("only for known customers, the order has no customer")
+	}
+	referrerID, err := program.Store.CodeOwner(code)
+	if err != nil {
+		return err
+	}
+	if referrerID == order.CustomerID {
+		return reject("customers can't refer themselves")
+	}
+	// Without a history every customer would look new, so the fraud rules can't be checked
+	provider := program.History
+	if provider == nil {
+		provider = order.History
+	}
+	if provider == nil {
+		return ErrNoReferralHistory
+	}
+	referee, err := provider.History(order.CustomerID)
+	if err != nil {
+		return err
+	}
+	referrer, err := provider.History(referrerID)
+	if err != nil {
+		return err
+	}
+	if referee.Orders > 0 {
+		return reject(fmt.Sprintf("only for new customers, customer has %d orders", referee.Orders))
+	}
+	if referee.Household != "" && referee.Household == referrer.Household {
+		return reject("customers can't refer someone in their own household")
+	}
+
+	referral := Referral{
+		Code:       code,
+		ReferrerID: referrerID,
+		RefereeID:  order.CustomerID,
+		OrderID:    order.ID,
+		Household:  referee.Household,
+		Status:     ReferralPending,
+		AcceptedAt: program.Now(),
+	}
+	// The referrals of other orders are checked by the store while it saves, so concurrent Accepts can't exceed the limits
+	err = program.Store.Add(referral, func(referrals []Referral) error {
+		household := 0
+		for _, existing := range referrals {
+			if existing.Status == ReferralVoid || existing.OrderID == order.ID {
+				continue
+			}
+			if existing.RefereeID == order.CustomerID {
+				return reject("customer was already referred")
+			}
+			if referee.Household != "" && existing.Household == referee.Household {
+				household++
+			}
+		}
+		if program.HouseholdLimit > 0 && household >= program.HouseholdLimit {
+			return reject(fmt.Sprintf("household reached the limit of %d referrals", program.HouseholdLimit))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	order.ReferralCode = code
+	order.Referrals = program.Store
+	program.emit(ReferralEvent{Status: ReferralPending, Referral: referral, Time: referral.AcceptedAt})
+	return nil
+}
+
+// OnOrderEvent follows the orders of referrals, it is subscribed to the Lifecycle of the orders.
+// A paid order starts the return window and a cancelled or fully refunded order voids the referral
+func (program *ReferralProgram) OnOrderEvent(event OrderEvent) {
+	referral, ok := program.referral(event.OrderID)
+	if !ok || referral.Status == ReferralRewarded || referral.Status == ReferralVoid {
+		return
+	}
+	switch event.To {
+	case StatePaid:
+		referral.Status = ReferralPaid
+		referral.PaidAt = event.Time
+		program.Store.Save(referral)
+	case StateCancelled, StateRefunded:
+		referral.Status = ReferralVoid
+		if program.Store.Save(referral) == nil {
+			program.emit(ReferralEvent{Status: ReferralVoid, Referral: referral, Time: event.Time})
+		}
+	}
+}
+
+func (program *ReferralProgram) referral(orderID string) (Referral, bool) {
+	referral, ok, err := program.Store.Referral(orderID)
+	return referral, ok && err == nil
+}
+
+// referralEligible returns why the order has no referral for promotions with CustomerCondition.Referred.
+// The ReferralCode of the order only counts when it was accepted for the order and the referral wasn't voided since
+func (order Order) referralEligible() string {
+	if order.ReferralCode == "" {
+		return "only for orders with a referral code"
+	}
+	if order.Referrals == nil {
+		return "referrals are not available"
+	}
+	referral, ok, err := order.Referrals.Referral(order.ID)
+	if err != nil {
+		return "referrals are not available: " + err.Error()
+	}
+	if !ok || referral.Code != order.ReferralCode || referral.Status == ReferralVoid {
+		return fmt.Sprintf("referral code %s wasn't accepted for the order", order.ReferralCode)
+	}
+	return ""
+}
+
+// IssueRewards rewards the referrers of every paid order whose return window has passed at now and returns the events.
+// A referral that another run rewarded or a refund voided since it was read is skipped
+func (program *ReferralProgram) IssueRewards(now time.Time) ([]ReferralEvent, error) {
+	referrals, err := program.Store.Referrals()
+	if err != nil {
+		return nil, err
+	}
+	var events []ReferralEvent
+	for _, referral := range referrals {
+		if referral.Status != ReferralPaid || now.Before(referral.PaidAt.Add(program.ReturnWindow)) {
+			continue
+		}
+		ok, err := program.Store.Transition(referral.OrderID, ReferralPaid, ReferralRewarded)
+		if err != nil {
+			return events, err
+		}
+		if !ok {
+			continue
+		}
+		referral.Status = ReferralRewarded
+		event := ReferralEvent{Status: ReferralRewarded, Referral: referral, Reward: program.Reward, Points: program.Points, Time: now}
+		program.emit(event)
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// MemoryReferralStore keeps referral codes and referrals in memory
+type MemoryReferralStore struct {
+	mu        sync.Mutex
+	codes     map[string]string // CustomerID of every code
+	referrals []Referral
+}
+
+func NewMemoryReferralStore() *MemoryReferralStore {
+	return &MemoryReferralStore{codes: make(map[string]string)}
+}
+
+// AddCode gives the customer a referral code. A code belongs to a single customer
+func (store *MemoryReferralStore) AddCode(code, customerID string) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if owner, ok := store.codes[code]; ok && owner != customerID {
+		return fmt.Errorf("referral code %s already belongs to customer %s", code, owner)
+	}
+	store.codes[code] = customerID
+	return nil
+}
+
+func (store *MemoryReferralStore) CodeOwner(code string) (string, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	owner, ok := store.codes[code]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownReferralCode, code)
+	}
+	return owner, nil
+}
+
+func (store *MemoryReferralStore) Referrals() ([]Referral, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	return append([]Referral(nil), store.referrals...), nil
+}
+
+func (store *MemoryReferralStore) Referral(orderID string) (Referral, bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	for _, referral := range store.referrals {
+		if referral.OrderID == orderID {
+			return referral, true, nil
+		}
+	}
+	return Referral{}, false, nil
+}
+
+func (store *MemoryReferralStore) Add(referral Referral, check func(referrals []Referral) error) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if err := check(append([]Referral(nil), store.referrals...)); err != nil {
+		return err
+	}
+	store.save(referral)
+	return nil
+}
+
+func (store *MemoryReferralStore) Save(referral Referral) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.save(referral)
+	return nil
+}
+
+func (store *MemoryReferralStore) Transition(orderID string, from, to ReferralStatus) (bool, error) {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	for i := range store.referrals {
+		if store.referrals[i].OrderID == orderID {
+			if store.referrals[i].Status != from {
+				return false, nil
+			}
+			store.referrals[i].Status = to
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// save must be called with the lock held
+func (store *MemoryReferralStore) save(referral Referral) {
+	for i := range store.referrals {
+		if store.referrals[i].OrderID == referral.OrderID {
+			store.referrals[i] = referral
+			return
+		}
+	}
+	store.referrals = append(store.referrals, referral)
+}
@@ -0,0 +1,197 @@
+package engine
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReferral(t *testing.T) {
+	paidAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	setup := func() (*ReferralProgram, *Lifecycle, *[]ReferralEvent) {
+		store := NewMemoryReferralStore()
+		store.AddCode("SOMCHAI10", "somchai")
+		history := NewMemoryHistoryProvider(
+			CustomerHistory{CustomerID: "somchai", Orders: 8, Household: "H1"},
+			CustomerHistory{CustomerID: "malee", Household: "H1"},
+			CustomerHistory{CustomerID: "niran", Household: "H2"},
+			CustomerHistory{CustomerID: "ploy", Household: "H2"},
+			CustomerHistory{CustomerID: "regular", Orders: 3, Household: "H3"},
+		)
+		program := NewReferralProgram(store, Promotion{PromName: "Thanks for referring", PromID: "BAHT", Value: 100}, 0)
+		program.History = history
+		program.HouseholdLimit = 1
+		var events []ReferralEvent
+		program.Subscribe(func(event ReferralEvent) { events = append(events, event) })
+		lc := NewLifecycle(nil)
+		lc.Now = func() time.Time { return paidAt }
+		lc.Subscribe(program.OnOrderEvent)
+		return program, lc, &events
+	}
+	refereeOrder := func(id, customer string) Order {
+		return Order{ID: id, CustomerID: customer, Items: []Item{
+			{SKU: "A", Price: 500, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		}, Promotions: []Promotion{
+			{PromName: "Referred 100 Baht off", PromID: "BAHT", Value: 100, Customer: CustomerCondition{Referred: true}},
+		}}
+	}
+
+	t.Run("Referee gets the discount and the referrer the reward after the return window", func(t *testing.T) {
+		program, lc, events := setup()
+		order := refereeOrder("1", "niran")
+		if err := program.Accept(&order, "SOMCHAI10"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		result, _ := lc.Price(&order)
+		if result.Discount != 100 {
+			t.Errorf("Expected discount to be 100, got %f", result.Discount)
+		}
+		lc.Lock(&order)
+		lc.Pay(&order)
+		if rewarded, _ := program.IssueRewards(paidAt.Add(13 * 24 * time.Hour)); len(rewarded) != 0 {
+			t.Errorf("Expected no rewards inside the return window, got %v", rewarded)
+		}
+		rewarded, err := program.IssueRewards(paidAt.Add(14 * 24 * time.Hour))
+		if err != nil || len(rewarded) != 1 {
+			t.Fatalf("Expected 1 reward, got %v and %v", rewarded, err)
+		}
+		if rewarded[0].Referral.ReferrerID != "somchai" || rewarded[0].Reward.Value != 100 {
+			t.Errorf("Expected a 100 Baht voucher for somchai, got %+v", rewarded[0])
+		}
+		if rewarded, _ := program.IssueRewards(paidAt.Add(30 * 24 * time.Hour)); len(rewarded) != 0 {
+			t.Errorf("Expected the reward to be issued once, got %v", rewarded)
+		}
+		if len(*events) != 2 || (*events)[0].Status != ReferralPending || (*events)[1].Status != ReferralRewarded {
+			t.Errorf("Expected accepted and rewarded events, got %v", *events)
+		}
+	})
+	t.Run("Refunded orders are not rewarded", func(t *testing.T) {
+		program, lc, _ := setup()
+		order := refereeOrder("1", "niran")
+		program.Accept(&order, "SOMCHAI10")
+		lc.Price(&order)
+		lc.Lock(&order)
+		lc.Pay(&order)
+		lc.Refund(&order, 400)
+		if rewarded, _ := program.IssueRewards(paidAt.Add(30 * 24 * time.Hour)); len(rewarded) != 0 {
+			t.Errorf("Expected no rewards for a refunded order, got %v", rewarded)
+		}
+	})
+	t.Run("Without a referral code", func(t *testing.T) {
+		order := refereeOrder("1", "niran")
+		order.CalcTotal()
+		order.CalcDiscount()
+		if order.Discount != 0 {
+			t.Errorf("Expected discount to be 0, got %f", order.Discount)
+		}
+	})
+	t.Run("Codes that weren't accepted for the order", func(t *testing.T) {
+		program, lc, _ := setup()
+		accepted := refereeOrder("1", "niran")
+		program.Accept(&accepted, "SOMCHAI10")
+		// Another order copies the code without going through Accept
+		copied := refereeOrder("2", "ploy")
+		copied.ReferralCode = "SOMCHAI10"
+		copied.Referrals = program.Store
+		if result, _ := lc.Price(&copied); result.Discount != 0 || result.Breakdown[0].Reason == "" {
+			t.Errorf("Expected no discount with a reason, got %+v", result)
+		}
+		program.OnOrderEvent(OrderEvent{OrderID: "1", From: StatePriced, To: StateCancelled, Time: paidAt})
+		if result, _ := lc.Price(&accepted); result.Discount != 0 {
+			t.Errorf("Expected no discount once the referral is void, got %f", result.Discount)
+		}
+	})
+	t.Run("Concurrent referrals keep to the household limit", func(t *testing.T) {
+		program, _, _ := setup()
+		orders := []Order{refereeOrder("1", "niran"), refereeOrder("2", "ploy")}
+		errs := make([]error, len(orders))
+		var wg sync.WaitGroup
+		for i := range orders {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs[i] = program.Accept(&orders[i], "SOMCHAI10")
+			}(i)
+		}
+		wg.Wait()
+		if (errs[0] == nil) == (errs[1] == nil) {
+			t.Errorf("Expected one of the household to be accepted, got %v", errs)
+		}
+	})
+	t.Run("Fraud rules", func(t *testing.T) {
+		program, _, _ := setup()
+		first := refereeOrder("1", "niran")
+		if err := program.Accept(&first, "SOMCHAI10"); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		cases := []struct {
+			name     string
+			customer string
+		}{
+			{"Self-referral", "somchai"},
+			{"Same household as the referrer", "malee"},
+			{"Customer already ordered", "regular"},
+			{"Household limit", "ploy"},
+			{"Walk-in customer", ""},
+		}
+		for _, c := range cases {
+			order := refereeOrder("2", c.customer)
+			if err := program.Accept(&order, "SOMCHAI10"); !errors.Is(err, ErrReferralRejected) {
+				t.Errorf("%s: expected ErrReferralRejected, got %v", c.name, err)
+			}
+			if order.ReferralCode != "" {
+				t.Errorf("%s: expected no referral code on the order", c.name)
+			}
+		}
+		again := refereeOrder("3", "niran")
+		if err := program.Accept(&again, "SOMCHAI10"); !errors.Is(err, ErrReferralRejected) {
+			t.Errorf("Expected ErrReferralRejected for a second referral, got %v", err)
+		}
+		order := refereeOrder("4", "someone")
+		if err := program.Accept(&order, "NOPE"); !errors.Is(err, ErrUnknownReferralCode) {
+			t.Errorf("Expected ErrUnknownReferralCode, got %v", err)
+		}
+	})
+	t.Run("Without a history", func(t *testing.T) {
+		program, _, _ := setup()
+		program.History = nil
+		order := refereeOrder("1", "niran")
+		if err := program.Accept(&order, "SOMCHAI10"); !errors.Is(err, ErrNoReferralHistory) {
+			t.Errorf("Expected ErrNoReferralHistory, got %v", err)
+		}
+		if order.ReferralCode != "" {
+			t.Errorf("Expected no referral code on the order")
+		}
+		// The history of the order is used when the program has none
+		order.History = NewMemoryHistoryProvider(CustomerHistory{CustomerID: "somchai", Orders: 8, Household: "H1"})
+		if err := program.Accept(&order, "SOMCHAI10"); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	})
+	t.Run("Concurrent runs reward once", func(t *testing.T) {
+		program, lc, _ := setup()
+		order := refereeOrder("1", "niran")
+		program.Accept(&order, "SOMCHAI10")
+		lc.Price(&order)
+		lc.Lock(&order)
+		lc.Pay(&order)
+		runs := make([][]ReferralEvent, 8)
+		var wg sync.WaitGroup
+		for i := range runs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				runs[i], _ = program.IssueRewards(paidAt.Add(14 * 24 * time.Hour))
+			}(i)
+		}
+		wg.Wait()
+		rewarded := 0
+		for _, events := range runs {
+			rewarded += len(events)
+		}
+		if rewarded != 1 {
+			t.Errorf("Expected 1 reward over all runs, got %d", rewarded)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// OrderRepository stores priced orders so they can be retrieved for receipts, returns and reporting.
+// An order is stored with its items, the promotions applied to it and the Total and Discount it was priced with.
+// Saving an order with an existing ID replaces it.
+type OrderRepository interface {
+	Save(ctx context.Context, order Order) error
+	Get(ctx context.Context, id string) (Order, error)
+	ByCustomer(ctx context.Context, customerID string) ([]Order, error)
+	// ByDateRange returns the orders created from the start up to but not including the end.
+	// Orders without a CreatedAt have no date and are never returned
+	ByDateRange(ctx context.Context, from, to time.Time) ([]Order, error)
+}
+
+var ErrOrderNotFound = errors.New("order not found")
+
+// MemoryOrderRepository keeps orders in a map. Orders are copied in and out so callers can't change stored orders
+type MemoryOrderRepository struct {
+	mu     sync.RWMutex
+	orders map[string]Order
+}
+
+func NewMemoryOrderRepository() *MemoryOrderRepository {
+	return &MemoryOrderRepository{orders: make(map[string]Order)}
+}
+
+func (repo *MemoryOrderRepository) Save(ctx context.Context, order Order) error {
+	if order.ID == "" {
+		return errors.New("order: ID is required to save an order")
+	}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	repo.orders[order.ID] = copyOrder(order)
+	return nil
+}
+
+func (repo *MemoryOrderRepository) Get(ctx context.Context, id string) (Order, error) {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	order, ok := repo.orders[id]
+	if !ok {
+		return Order{}, ErrOrderNotFound
+	}
+	return copyOrder(order), nil
+}
+
+func (repo *MemoryOrderRepository) ByCustomer(ctx context.Context, customerID string) ([]Order, error) {
+	return repo.filter(func(order Order) bool { return order.CustomerID == customerID }), nil
+}
+
+func (repo *MemoryOrderRepository) ByDateRange(ctx context.Context, from, to time.Time) ([]Order, error) {
+	return repo.filter(func(order Order) bool {
+		return !order.CreatedAt.IsZero() && !order.CreatedAt.Before(from) && order.CreatedAt.Before(to)
+	}), nil
+}
+
+// filter returns the matching orders sorted by creation time and ID, the same order the SQLite repository returns
+func (repo *MemoryOrderRepository) filter(match func(Order) bool) []Order {
+	repo.mu.RLock()
+	defer repo.mu.RUnlock()
+	var orders []Order
+	for _, order := range repo.orders {
+		if match(order) {
+			orders = append(orders, copyOrder(order))
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		if !orders[i].CreatedAt.Equal(orders[j].CreatedAt) {
+			return orders[i].CreatedAt.Before(orders[j].CreatedAt)
+		}
+		return orders[i].ID < orders[j].ID
+	})
+	return orders
+}
+
+func copyOrder(order Order) Order {
+	order.Items = append([]Item(nil), order.Items...)
+	order.Promotions = append([]Promotion(nil), order.Promotions...)
+	order.Adjustments = append([]Adjustment(nil), order.Adjustments...)
+	order.Breakdown = append([]PromotionResult(nil), order.Breakdown...)
+	order.Given = append([]AppliedAdjustment(nil), order.Given...)
+	return order
+}
@@ -0,0 +1,181 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Both repositories should behave the same so they share the test cases
+func testOrderRepository(t *testing.T, repo OrderRepository) {
+	ctx := context.Background()
+	day := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	orders := []Order{
+		{ID: "1", CustomerID: "C1", CreatedAt: day, Items: []Item{
+			{SKU: "A", Price: 600, Amount: 3, ValidSelectedItem: true, ValidFreeItem: false, ValidFiftyOff: false},
+			{SKU: "B", Price: 30.5, Amount: 1, ValidSelectedItem: false, ValidFreeItem: true, ValidFiftyOff: true},
+		}, Promotions: []Promotion{
+			{PromName: "Buy2Get1Free", PromID: "B2G1"},
+			{PromName: "1 15%, 2 20%, 3 30%", PromID: "INCD", Cap: 500, Scope: Scope{Channels: []Channel{ChannelWeb}}},
+		}, Context: SalesContext{Channel: ChannelWeb, Region: "BKK", Zone: "Z1"}, ReferralCode: "FRIEND", Payment: Payment{Method: PayCard, BIN: "40458612"}, Adjustments: []Adjustment{
+			{Kind: LineDiscount, Line: 1, Amount: 5, Reason: "DAMAGED", StaffID: "S1"},
+		}},
+		{ID: "2", CustomerID: "C2", CreatedAt: day.Add(24 * time.Hour), Items: []Item{
+			{SKU: "A", Price: 600, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		}},
+		{ID: "3", CustomerID: "C1", CreatedAt: day.Add(48 * time.Hour)},
+		{ID: "4", CustomerID: "C3"},
+	}
+	policy := PricingPolicy{Adjustments: AdjustmentPolicy{Limits: adjustmentLimits, Staff: adjustmentStaff, Interaction: Better}}
+	for i := range orders {
+		policy.Price(&orders[i])
+		if err := repo.Save(ctx, orders[i]); err != nil {
+			t.Fatalf("Expected no error saving order %s, got %v", orders[i].ID, err)
+		}
+	}
+
+	t.Run("Get keeps the items, promotions and pricing snapshot", func(t *testing.T) {
+		order, err := repo.Get(ctx, "1")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(order.Items) != 2 || order.Items[1] != orders[0].Items[1] || len(order.Promotions) != 2 || order.Promotions[1].Cap != 500 || len(order.Adjustments) != 1 || order.Adjustments[0] != orders[0].Adjustments[0] {
+			t.Errorf("Expected the saved items and promotions, got %v", order)
+		}
+		if order.Context != orders[0].Context || order.ReferralCode != "FRIEND" || order.Payment != orders[0].Payment || len(order.Promotions[1].Scope.Channels) != 1 {
+			t.Errorf("Expected the sales context and scope, got %+v and %+v", order.Context, order.Promotions[1].Scope)
+		}
+		if order.Total != 1830.5 || order.Discount != 600 || !order.CreatedAt.Equal(day) {
+			t.Errorf("Expected total 1830.5 and discount 600, got %f and %f", order.Total, order.Discount)
+		}
+		if order.Applied.PromID != "B2G1" || order.Applied.Discount != 600 || len(order.Breakdown) != 2 || order.Breakdown[1] != orders[0].Breakdown[1] {
+			t.Errorf("Expected B2G1 applied with the breakdown of both promotions, got %+v and %+v", order.Applied, order.Breakdown)
+		}
+		if _, err := repo.Get(ctx, "404"); !errors.Is(err, ErrOrderNotFound) {
+			t.Errorf("Expected ErrOrderNotFound, got %v", err)
+		}
+	})
+	t.Run("Order without CreatedAt", func(t *testing.T) {
+		// Without a date the order must not get windowed, birthday or win-back promotions once it is loaded
+		order, err := repo.Get(ctx, "4")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if !order.CreatedAt.IsZero() {
+			t.Errorf("Expected the zero CreatedAt, got %v", order.CreatedAt)
+		}
+		found, _ := repo.ByDateRange(ctx, time.Time{}, day.Add(72*time.Hour))
+		if len(found) != 3 {
+			t.Errorf("Expected orders 1, 2 and 3 without order 4, got %v", found)
+		}
+	})
+	t.Run("Given adjustments", func(t *testing.T) {
+		order := orders[0]
+		order.ID, order.CustomerID = "5", "C5"
+		PricingPolicy{Adjustments: AdjustmentPolicy{Limits: adjustmentLimits, Staff: adjustmentStaff, Interaction: Stack}}.Price(&order)
+		repo.Save(ctx, order)
+		saved, _ := repo.Get(ctx, "5")
+		if len(saved.Given) != 1 || saved.Given[0] != order.Given[0] {
+			t.Errorf("Expected the line discount to be given, got %+v", saved.Given)
+		}
+	})
+	t.Run("Saving again replaces the order", func(t *testing.T) {
+		order := orders[1]
+		order.Items = append(order.Items, Item{SKU: "C", Price: 10, Amount: 1})
+		order.Price()
+		repo.Save(ctx, order)
+		saved, _ := repo.Get(ctx, "2")
+		if len(saved.Items) != 2 || saved.Total != 610 {
+			t.Errorf("Expected 2 items with total 610, got %v", saved)
+		}
+	})
+	t.Run("By customer", func(t *testing.T) {
+		found, _ := repo.ByCustomer(ctx, "C1")
+		if len(found) != 2 || found[0].ID != "1" || found[1].ID != "3" {
+			t.Errorf("Expected orders 1 and 3, got %v", found)
+		}
+	})
+	t.Run("By date range", func(t *testing.T) {
+		// The end of the range is excluded so order 3 isn't returned
+		found, _ := repo.ByDateRange(ctx, day.Add(time.Hour), day.Add(48*time.Hour))
+		if len(found) != 1 || found[0].ID != "2" {
+			t.Errorf("Expected order 2, got %v", found)
+		}
+	})
+}
+
+func TestMemoryOrderRepository(t *testing.T) {
+	testOrderRepository(t, NewMemoryOrderRepository())
+}
+
+func TestSQLiteOrderRepository(t *testing.T) {
+	repo, err := OpenSQLiteOrderRepository(filepath.Join(t.TempDir(), "orders.db"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer repo.Close()
+	testOrderRepository(t, repo)
+}
+
+func TestSQLiteMigrations(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.db")
+	// A database written by the first build, before the schema had a version
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	_, err = db.Exec(`INSERT INTO orders (id, customer_id, created_at, total, discount) VALUES ('1', 'C1', 0, 1800, 600);
+INSERT INTO order_items (order_id, line, sku, price, amount, valid_selected_item, valid_free_item, valid_fifty_off) VALUES ('1', 0, 'A', 600, 3, 1, 0, 0);
+INSERT INTO order_promotions (order_id, position, prom_id, config) VALUES ('1', 0, 'B2G1', '{"PromName":"Buy2Get1Free","PromID":"B2G1"}');`)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	db.Close()
+
+	repo, err := OpenSQLiteOrderRepository(path)
+	if err != nil {
+		t.Fatalf("Expected the old database to be migrated, got %v", err)
+	}
+	defer repo.Close()
+	t.Run("Old orders are read with the defaults of the new columns", func(t *testing.T) {
+		order, err := repo.Get(ctx, "1")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(order.Items) != 1 || order.Items[0].Amount != 3 || order.Items[0].Unit != "" || order.State != "" || order.Adjustments != nil || order.Payment != (Payment{}) || len(order.Breakdown) != 0 {
+			t.Errorf("Expected the old order with empty new fields, got %+v", order)
+		}
+	})
+	t.Run("New orders are saved in the migrated tables", func(t *testing.T) {
+		order := Order{ID: "2", CustomerID: "C1", Items: []Item{
+			{SKU: "A", Price: 600, Amount: 3, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		}, Promotions: []Promotion{{PromName: "Buy2Get1Free", PromID: "B2G1"}}, Locale: LocaleThai}
+		order.Price()
+		if err := repo.Save(ctx, order); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		saved, _ := repo.Get(ctx, "2")
+		if saved.Locale != LocaleThai || saved.Applied.PromID != "B2G1" || len(saved.Breakdown) != 1 {
+			t.Errorf("Expected the locale and the applied promotion, got %+v", saved)
+		}
+	})
+	t.Run("Opening again keeps the version", func(t *testing.T) {
+		var version int
+		repo.db.QueryRow(`PRAGMA user_version`).Scan(&version)
+		if version != len(sqliteMigrations) {
+			t.Errorf("Expected version %d, got %d", len(sqliteMigrations), version)
+		}
+		again, err := OpenSQLiteOrderRepository(path)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		again.Close()
+	})
+}
@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+)
+
+// RoundingMode decides which way the payable is rounded to the increment
+type RoundingMode string
+
+const (
+	RoundNearest RoundingMode = ""        // Nearest increment, halfway rounds up
+	RoundDown    RoundingMode = "down"    // Always down so the customer never pays more
+	RoundSwedish RoundingMode = "swedish" // Nearest increment, halfway rounds down in favour of the customer
+)
+
+// Rounding rounds the payable to a multiple of Increment, like 0.25 Baht for cash in Thailand. An Increment of 0 doesn't round
+type Rounding struct {
+	Mode      RoundingMode
+	Increment float64
+}
+
+// Round returns the rounded amount and the difference to the amount, negative when it was rounded down.
+// Amounts are rounded in satang so float errors don't move an amount over a halfway point
+func (rounding Rounding) Round(amount float64) (float64, float64) {
+	increment := math.Round(rounding.Increment * 100)
+	if increment <= 0 {
+		return amount, 0
+	}
+	satang := math.Round(amount * 100)
+	steps := satang / increment
+	switch rounding.Mode {
+	case RoundDown:
+		steps = math.Floor(steps)
+	case RoundSwedish:
+		steps = math.Ceil(steps - 0.5)
+	default:
+		steps = math.Floor(steps + 0.5)
+	}
+	rounded := steps * increment / 100
+	return rounded, math.Round((rounded-amount)*100) / 100
+}
+
+func (rounding Rounding) validate(field string) ValidationErrors {
+	var errs ValidationErrors
+	switch rounding.Mode {
+	case RoundNearest, RoundDown, RoundSwedish:
+	default:
+		errs = append(errs, ValidationError{Field: field + ".Mode", Reason: fmt.Sprintf("unknown rounding mode %q", rounding.Mode)})
+	}
+	if rounding.Increment < 0 || math.IsNaN(rounding.Increment) {
+		errs = append(errs, ValidationError{Field: field + ".Increment", Reason: fmt.Sprintf("must not be negative, got %g", rounding.Increment)})
+	}
+	return errs
+}
+
+// RoundingPolicy picks the rounding by how the order is paid. Tenders without their own rounding use Default,
+// so cash can be rounded to 0.25 Baht while card payments are paid to the satang
+type RoundingPolicy struct {
+	Default Rounding
+	Tenders map[PaymentMethod]Rounding
+}
+
+// ThaiCashRounding rounds cash payments to the nearest 0.25 Baht and leaves other payments as they are
+var ThaiCashRounding = RoundingPolicy{Tenders: map[PaymentMethod]Rounding{PayCash: {Increment: 0.25}}}
+
+func (policy RoundingPolicy) For(method PaymentMethod) Rounding {
+	if rounding, ok := policy.Tenders[method]; ok {
+		return rounding
+	}
+	return policy.Default
+}
+
+// Price prices the order and rounds the payable for the payment of the order, see PricingPolicy
+func (policy RoundingPolicy) Price(order *Order) (PricingResult, error) {
+	return PricingPolicy{Rounding: policy}.Price(order)
+}
+
+func (policy RoundingPolicy) validate() ValidationErrors {
+	errs := policy.Default.validate("Rounding.Default")
+	for method, rounding := range policy.Tenders {
+		errs = append(errs, rounding.validate(fmt.Sprintf("Rounding.Tenders[%s]", method))...)
+	}
+	return errs
+}
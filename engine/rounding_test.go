@@ -0,0 +1,73 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestRounding(t *testing.T) {
+	cases := []struct {
+		rounding Rounding
+		amount   float64
+		expected float64
+	}{
+		{Rounding{Increment: 0.25}, 101.12, 101.00},
+		{Rounding{Increment: 0.25}, 101.13, 101.25},
+		{Rounding{Increment: 0.10}, 10.05, 10.10},
+		{Rounding{Mode: RoundSwedish, Increment: 0.10}, 10.05, 10.00},
+		{Rounding{Mode: RoundSwedish, Increment: 0.25}, 101.13, 101.25},
+		{Rounding{Mode: RoundDown, Increment: 0.25}, 101.24, 101.00},
+		{Rounding{Mode: RoundDown, Increment: 1}, 99.99, 99.00},
+		{Rounding{Increment: 0.05}, 10.07, 10.05},
+		{Rounding{Increment: 0.05}, 10.08, 10.10},
+		{Rounding{}, 10.07, 10.07},
+	}
+	for _, c := range cases {
+		rounded, difference := c.rounding.Round(c.amount)
+		if rounded != c.expected {
+			t.Errorf("Expected %+v of %.3f to be %.2f, got %.2f", c.rounding, c.amount, c.expected, rounded)
+		}
+		if diff := rounded - c.amount - difference; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("Expected difference of %.3f to be %.2f, got %.2f", c.amount, rounded-c.amount, difference)
+		}
+	}
+	t.Run("Only cash is rounded", func(t *testing.T) {
+		order := Order{ID: "1", Items: []Item{
+			{SKU: "A", Price: 201.10, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		}, Promotions: []Promotion{{PromName: "50% Off", PromID: "HOFF"}}, Payment: Payment{Method: PayCash}}
+		result, err := ThaiCashRounding.Price(&order)
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		// 100.55 payable is rounded to 100.50 with a rounding line of -0.05, the discount stays 100.55
+		if result.Discount != 100.55 || result.Rounding != -0.05 || result.Payable() != 100.50 || order.Payable() != 100.50 {
+			t.Errorf("Expected discount 100.55 and rounding -0.05, got %f and %f", result.Discount, result.Rounding)
+		}
+		order.Payment = Payment{Method: PayCard}
+		result, _ = ThaiCashRounding.Price(&order)
+		if result.Rounding != 0 || order.Rounding != 0 {
+			t.Errorf("Expected no rounding for card payments, got %f", result.Rounding)
+		}
+	})
+	t.Run("Rounding composes with budgets and the lifecycle", func(t *testing.T) {
+		lc := NewLifecycle(nil)
+		lc.Pricing = PricingPolicy{Budgets: NewMemoryBudgetLedger(Budget{PromID: "HOFF", Limit: 60.1, Mode: BudgetPartial}), Rounding: ThaiCashRounding}
+		order := Order{ID: "1", Items: []Item{
+			{SKU: "A", Price: 201.20, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		}, Promotions: []Promotion{{PromName: "50% Off", PromID: "HOFF"}}, Payment: Payment{Method: PayCash}}
+		// HOFF is limited to the 60.10 left of its budget, the 141.10 payable is rounded to 141.00
+		result, err := lc.Price(&order)
+		if err != nil || result.Discount != 60.1 || result.Rounding != -0.1 || order.Payable() != 141 {
+			t.Fatalf("Expected discount 60.10 and rounding -0.10, got %+v and %v", result, err)
+		}
+		lc.Lock(&order)
+		if order.Rounding != -0.1 || order.Payable() != 141 {
+			t.Errorf("Expected the rounding to stay when the order is locked, got %f", order.Rounding)
+		}
+	})
+	t.Run("Unknown mode", func(t *testing.T) {
+		order := Order{ID: "1"}
+		if _, err := (RoundingPolicy{Default: Rounding{Mode: "banker", Increment: 1}}).Price(&order); err == nil {
+			t.Errorf("Expected error for unknown rounding mode")
+		}
+	})
+}
@@ -0,0 +1,222 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Scenario is a golden fixture of an order and the pricing it is expected to get. Scenarios are JSON files so cases can be
+// added without writing Go. Clock and Customer are optional and replace CreatedAt and CustomerID of the order.
+// History is the customer history for promotions with a customer condition.
+// When Promotions is empty the promotions of the order are used. Pricing is the adjustment and rounding policy of the order,
+// the zero policy when the file has none.
+type Scenario struct {
+	Name       string            `json:"name"`
+	Clock      *time.Time        `json:"clock,omitempty"`
+	Customer   string            `json:"customer,omitempty"`
+	History    []CustomerHistory `json:"history,omitempty"`
+	Order      Order             `json:"order"`
+	Promotions []Promotion       `json:"promotions,omitempty"`
+	Pricing    PricingPolicy     `json:"pricing"`
+	Expected   Expectation       `json:"expected"`
+
+	path string
+	raw  []scenarioField // The file as it was written so updating the golden result keeps the rest of the file
+}
+
+// scenarioField is a top level key of a scenario file with its value as written
+type scenarioField struct {
+	key   string
+	value json.RawMessage
+}
+
+// Expectation is the golden result of a scenario. Applied is the PromID of the winning promotion, empty when there is no discount
+type Expectation struct {
+	Error     string            `json:"error,omitempty"`
+	Total     float64           `json:"total"`
+	Discount  float64           `json:"discount"`
+	Applied   string            `json:"applied,omitempty"`
+	Breakdown []PromotionResult `json:"breakdown,omitempty"`
+}
+
+// scenarioTolerance is how far a float can be from the golden value, the values in the files are written to the Baht satang
+const scenarioTolerance = 0.005
+
+// LoadScenarios reads every .json file in the directory, sorted by file name
+func LoadScenarios(dir string) ([]Scenario, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	scenarios := make([]Scenario, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var scenario Scenario
+		if err := json.Unmarshal(data, &scenario); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if scenario.raw, err = readFields(data); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if scenario.Name == "" {
+			scenario.Name = filepath.Base(path)
+		}
+		scenario.path = path
+		scenarios = append(scenarios, scenario)
+	}
+	return scenarios, nil
+}
+
+// Run prices the order of the scenario and returns what it actually got
+func (scenario Scenario) Run() Expectation {
+	order := copyOrder(scenario.Order)
+	if scenario.Clock != nil {
+		order.CreatedAt = *scenario.Clock
+	}
+	if scenario.Customer != "" {
+		order.CustomerID = scenario.Customer
+	}
+	if len(scenario.History) > 0 {
+		order.History = NewMemoryHistoryProvider(scenario.History...)
+	}
+	if len(scenario.Promotions) > 0 {
+		order.Promotions = scenario.Promotions
+	}
+	result, err := scenario.Pricing.Price(&order)
+	if err != nil {
+		return Expectation{Error: err.Error()}
+	}
+	return Expectation{
+		Total:     result.Total,
+		Discount:  result.Discount,
+		Applied:   result.Applied.PromID,
+		Breakdown: result.Breakdown,
+	}
+}
+
+// Diff compares the actual result with the expected one. The breakdown is only compared when the scenario has one
+func (expected Expectation) Diff(actual Expectation) []string {
+	var diffs []string
+	if expected.Error != actual.Error {
+		diffs = append(diffs, fmt.Sprintf("error: expected %q, got %q", expected.Error, actual.Error))
+	}
+	if math.Abs(expected.Total-actual.Total) > scenarioTolerance {
+		diffs = append(diffs, fmt.Sprintf("total: expected %.2f, got %.2f", expected.Total, actual.Total))
+	}
+	if math.Abs(expected.Discount-actual.Discount) > scenarioTolerance {
+		diffs = append(diffs, fmt.Sprintf("discount: expected %.2f, got %.2f", expected.Discount, actual.Discount))
+	}
+	if expected.Applied != actual.Applied {
+		diffs = append(diffs, fmt.Sprintf("applied: expected %q, got %q", expected.Applied, actual.Applied))
+	}
+	if len(expected.Breakdown) == 0 {
+		return diffs
+	}
+	if len(expected.Breakdown) != len(actual.Breakdown) {
+		return append(diffs, fmt.Sprintf("breakdown: expected %d promotions, got %d", len(expected.Breakdown), len(actual.Breakdown)))
+	}
+	for i, prom := range expected.Breakdown {
+		got := actual.Breakdown[i]
+		if prom.PromID != got.PromID || math.Abs(prom.Discount-got.Discount) > scenarioTolerance || prom.Reason != got.Reason {
+			diffs = append(diffs, fmt.Sprintf("breakdown[%d]: expected %s %.2f %q, got %s %.2f %q", i, prom.PromID, prom.Discount, prom.Reason, got.PromID, got.Discount, got.Reason))
+		}
+	}
+	return diffs
+}
+
+// ScenarioFailure is a scenario that didn't get its expected result
+type ScenarioFailure struct {
+	Name  string
+	Path  string
+	Diffs []string
+}
+
+// RunScenarios runs every scenario and returns the ones that failed. With update the actual results are written back
+// to the files as the new golden results instead of failing
+func RunScenarios(scenarios []Scenario, update bool) ([]ScenarioFailure, error) {
+	var failures []ScenarioFailure
+	for _, scenario := range scenarios {
+		actual := scenario.Run()
+		diffs := scenario.Expected.Diff(actual)
+		if len(diffs) == 0 {
+			continue
+		}
+		if update {
+			scenario.Expected = actual
+			if err := scenario.write(); err != nil {
+				return failures, err
+			}
+			continue
+		}
+		failures = append(failures, ScenarioFailure{Name: scenario.Name, Path: scenario.path, Diffs: diffs})
+	}
+	return failures, nil
+}
+
+// readFields returns the top level keys of the JSON object in the order they are written
+func readFields(data []byte) ([]scenarioField, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil {
+		return nil, err
+	} else if token != json.Delim('{') {
+		return nil, fmt.Errorf("expected a JSON object, got %v", token)
+	}
+	var fields []scenarioField
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		fields = append(fields, scenarioField{key: token.(string), value: value})
+	}
+	return fields, nil
+}
+
+// write replaces the expected result in the file, or adds it at the end when the file has none.
+// Every other key is written back as it was, in the order of the file
+func (scenario Scenario) write() error {
+	expected, err := json.Marshal(scenario.Expected)
+	if err != nil {
+		return err
+	}
+	fields := append([]scenarioField(nil), scenario.raw...)
+	replaced := false
+	for i := range fields {
+		if fields[i].key == "expected" {
+			fields[i].value = expected
+			replaced = true
+		}
+	}
+	if !replaced {
+		fields = append(fields, scenarioField{key: "expected", value: expected})
+	}
+	var buf bytes.Buffer
+	buf.WriteString("{\n")
+	for i, f := range fields {
+		var value bytes.Buffer
+		if err := json.Indent(&value, f.value, "  ", "  "); err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "  %q: %s", f.key, value.String())
+		if i < len(fields)-1 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}\n")
+	return os.WriteFile(scenario.path, buf.Bytes(), 0o644)
+}
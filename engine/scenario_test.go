@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateGoldens = flag.Bool("update", false, "write the actual results of testdata/scenarios as the new golden results")
+
+func TestScenarios(t *testing.T) {
+	scenarios, err := LoadScenarios(filepath.Join("testdata", "scenarios"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scenarios) == 0 {
+		t.Fatal("Expected scenarios in testdata/scenarios")
+	}
+	failures, err := RunScenarios(scenarios, *updateGoldens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, failure := range failures {
+		for _, diff := range failure.Diffs {
+			t.Errorf("%s (%s): %s", failure.Name, failure.Path, diff)
+		}
+	}
+}
+
+func TestScenarioUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scenario.json")
+	data := `{"name": "Half off", "expected": {"total": 100, "discount": 10}, "order": {"ID": "1", "Items": [{"SKU": "A", "Price": 100, "Amount": 1}]}, "promotions": [{"PromName": "50% Off", "PromID": "HOFF"}], "note": "Keep"}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	scenarios, err := LoadScenarios(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("Diff is reported", func(t *testing.T) {
+		failures, err := RunScenarios(scenarios, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(failures) != 1 || len(failures[0].Diffs) != 2 {
+			t.Fatalf("Expected a discount and applied diff, got %+v", failures)
+		}
+	})
+	t.Run("Update writes the golden result", func(t *testing.T) {
+		if _, err := RunScenarios(scenarios, true); err != nil {
+			t.Fatal(err)
+		}
+		updated, err := LoadScenarios(filepath.Dir(path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated[0].Expected.Discount != 50 || updated[0].Expected.Applied != "HOFF" {
+			t.Errorf("Expected discount to be 50 from HOFF, got %f from %q", updated[0].Expected.Discount, updated[0].Expected.Applied)
+		}
+		if updated[0].Order.ID != "1" || updated[0].Name != "Half off" {
+			t.Errorf("Expected the rest of the file to be kept, got %+v", updated[0])
+		}
+		var keys []string
+		for _, field := range updated[0].raw {
+			keys = append(keys, field.key)
+		}
+		if strings.Join(keys, ",") != "name,expected,order,promotions,note" || string(updated[0].raw[4].value) != `"Keep"` {
+			t.Errorf("Expected every key of the file in its order, got %v", keys)
+		}
+		failures, err := RunScenarios(updated, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(failures) != 0 {
+			t.Errorf("Expected no failures after update, got %+v", failures)
+		}
+	})
+}
+
+func TestEligible(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	until := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+	prom := Promotion{PromName: "50% Off", PromID: "HOFF", ValidFrom: from, ValidUntil: until}
+	cases := []struct {
+		clock    time.Time
+		eligible bool
+	}{
+		{from.Add(-time.Second), false},
+		{from, true},
+		{until.Add(-time.Second), true},
+		{until, false},
+		// Without CreatedAt the order isn't priced at the current time
+		{time.Time{}, false},
+	}
+	for _, c := range cases {
+		order := Order{ID: "1", CreatedAt: c.clock, Items: []Item{
+			{SKU: "A", Price: 100, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		}}
+		eligible, reason := prom.Eligible(order)
+		if eligible != c.eligible {
+			t.Errorf("Expected eligible to be %v at %s, got %v (%s)", c.eligible, c.clock, eligible, reason)
+		}
+		if discount := prom.Apply(order); !eligible && discount != 0 {
+			t.Errorf("Expected discount to be 0 at %s, got %f", c.clock, discount)
+		}
+	}
+}
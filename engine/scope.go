@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Channel is where an order is sold
+type Channel string
+
+const (
+	ChannelStore Channel = "store" // Physical stores
+	ChannelWeb   Channel = "web"
+	ChannelLine  Channel = "line" // LINE shop
+)
+
+func (channel Channel) Valid() bool {
+	switch channel {
+	case "", ChannelStore, ChannelWeb, ChannelLine:
+		return true
+	}
+	return false
+}
+
+// SalesContext is where the order is sold. Zone is the delivery zone of web and LINE orders, empty for orders taken in store
+type SalesContext struct {
+	Channel Channel
+	StoreID string
+	Region  string
+	Zone    string
+}
+
+// Scope limits a promotion to channels, stores, regions and delivery zones. An empty list allows every value,
+// so the zero Scope is a promotion that runs everywhere
+type Scope struct {
+	Channels []Channel
+	Stores   []string
+	Regions  []string
+	Zones    []string
+}
+
+// inScope returns why the sales context is outside the scope, or an empty string when it is in scope
+func (scope Scope) inScope(sales SalesContext) string {
+	checks := []struct {
+		name    string
+		value   string
+		allowed []string
+	}{
+		{"channel", string(sales.Channel), scope.channels()},
+		{"store", sales.StoreID, scope.Stores},
+		{"region", sales.Region, scope.Regions},
+		{"zone", sales.Zone, scope.Zones},
+	}
+	for _, check := range checks {
+		if len(check.allowed) == 0 {
+			continue
+		}
+		if check.value == "" {
+			return fmt.Sprintf("order has no %s, the promotion is only for %s %s", check.name, check.name, strings.Join(check.allowed, ", "))
+		}
+		if !contains(check.allowed, check.value) {
+			return fmt.Sprintf("not available for %s %s, only for %s", check.name, check.value, strings.Join(check.allowed, ", "))
+		}
+	}
+	return ""
+}
+
+func (scope Scope) channels() []string {
+	channels := make([]string, len(scope.Channels))
+	for i, channel := range scope.Channels {
+		channels[i] = string(channel)
+	}
+	return channels
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func validateScope(field string, scope Scope) ValidationErrors {
+	var errs ValidationErrors
+	for i, channel := range scope.Channels {
+		if channel == "" || !channel.Valid() {
+			errs = append(errs, ValidationError{Field: fmt.Sprintf("%s.Channels[%d]", field, i), Reason: fmt.Sprintf("unknown channel %q", channel)})
+		}
+	}
+	return errs
+}
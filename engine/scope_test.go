@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestScope(t *testing.T) {
+	items := []Item{
+		{SKU: "A", Price: 1200, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+	}
+	promotions := []Promotion{
+		{PromName: "Web 50% off", PromID: "HOFF", Scope: Scope{Channels: []Channel{ChannelWeb, ChannelLine}}},
+		{PromName: "Siam branch 100 Baht off", PromID: "D100", Scope: Scope{Stores: []string{"S01"}}},
+		{PromName: "Bangkok delivery 15% off", PromID: "INCD", Scope: Scope{Regions: []string{"BKK"}, Zones: []string{"Z1", "Z2"}}},
+	}
+	cases := []struct {
+		name     string
+		sales    SalesContext
+		discount float64
+		applied  string
+	}{
+		{"Web order", SalesContext{Channel: ChannelWeb, Region: "CNX", Zone: "Z9"}, 600, "HOFF"},
+		{"Store order", SalesContext{Channel: ChannelStore, StoreID: "S01", Region: "BKK"}, 100, "D100"},
+		{"Other store", SalesContext{Channel: ChannelStore, StoreID: "S02", Region: "BKK"}, 0, ""},
+		{"LINE order delivered in Bangkok", SalesContext{Channel: ChannelLine, Region: "BKK", Zone: "Z2"}, 600, "HOFF"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			order := Order{ID: "1", Items: items, Promotions: promotions, Context: c.sales}
+			result, err := order.Price()
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if result.Discount != c.discount || result.Applied.PromID != c.applied {
+				t.Errorf("Expected discount to be %f from %q, got %f from %q", c.discount, c.applied, result.Discount, result.Applied.PromID)
+			}
+		})
+	}
+	t.Run("Out of scope promotions have a reason", func(t *testing.T) {
+		order := Order{ID: "1", Items: items, Promotions: promotions, Context: SalesContext{Channel: ChannelStore, StoreID: "S02"}}
+		order.CalcTotal()
+		for _, result := range order.Evaluate() {
+			if result.Discount != 0 || result.Reason == "" {
+				t.Errorf("Expected %s to be rejected with a reason, got %f %q", result.PromID, result.Discount, result.Reason)
+			}
+		}
+	})
+	t.Run("Unknown channel", func(t *testing.T) {
+		order := Order{ID: "1", Items: items, Context: SalesContext{Channel: "fax"}, Promotions: []Promotion{
+			{PromName: "Fax 50% off", PromID: "HOFF", Scope: Scope{Channels: []Channel{"fax"}}},
+		}}
+		if err := order.Validate(); err == nil {
+			t.Errorf("Expected error for unknown channel")
+		}
+	})
+}
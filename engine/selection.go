@@ -0,0 +1,52 @@
+package engine
+
+// Selection is the policy for picking the unit that a promotion gives away or discounts when more than one line qualifies
+type Selection string
+
+const (
+	SelectMostExpensive  Selection = "" // The default, which gives the customer the biggest discount
+	SelectCheapest       Selection = "cheapest"
+	SelectCustomerChosen Selection = "customer_chosen" // Only lines the customer marked as Chosen qualify, the first of them is picked
+	SelectFirstScanned   Selection = "first_scanned"   // The qualifying line that comes first in Order.Items
+)
+
+func (selection Selection) Valid() bool {
+	switch selection {
+	case SelectMostExpensive, SelectCheapest, SelectCustomerChosen, SelectFirstScanned:
+		return true
+	}
+	return false
+}
+
+// selectItem picks the qualifying item by the Selection of the promotion. false is returned when no item qualifies.
+// When two items have the same price the first one is picked, same as the left promotion is chosen by CalcDiscount
+func (prom Promotion) selectItem(items []Item, qualifies func(Item) bool) (Item, bool) {
+	var selected Item
+	found := false
+	for _, item := range items {
+		if !qualifies(item) {
+			continue
+		}
+		switch prom.Selection {
+		case SelectCustomerChosen:
+			if !item.Chosen || found {
+				continue
+			}
+		case SelectCheapest:
+			if found && item.Price >= selected.Price {
+				continue
+			}
+		case SelectFirstScanned:
+			if found {
+				continue
+			}
+		default:
+			if found && item.Price <= selected.Price {
+				continue
+			}
+		}
+		selected = item
+		found = true
+	}
+	return selected, found
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestSelection(t *testing.T) {
+	items := []Item{
+		{SKU: "A", Price: 50, Amount: 3, ValidSelectedItem: true, ValidFreeItem: false, ValidFiftyOff: true},
+		{SKU: "B", Price: 80, Amount: 3, ValidSelectedItem: true, ValidFreeItem: true, ValidFiftyOff: true},
+		{SKU: "C", Price: 20, Amount: 3, ValidSelectedItem: false, ValidFreeItem: true, ValidFiftyOff: true, Chosen: true},
+		{SKU: "D", Price: 60, Amount: 1, ValidSelectedItem: false, ValidFreeItem: true, ValidFiftyOff: false},
+	}
+	// Every promotion that gives away or discounts a unit should follow the selection the same way
+	cases := []struct {
+		selection Selection
+		b2g1      float64
+		b1n1      float64
+		b2i1      float64
+		b1nh      float64
+	}{
+		{SelectMostExpensive, 80, 79, 80, 40},
+		{SelectCheapest, 20, 19, 20, 10},
+		{SelectFirstScanned, 50, 49, 80, 25},
+		{SelectCustomerChosen, 20, 19, 20, 10},
+	}
+	for _, c := range cases {
+		order := Order{ID: "1", Items: items, Promotions: []Promotion{
+			{PromName: "Buy2Get1Free", PromID: "B2G1", Selection: c.selection},
+			{PromName: "Buy 1 get next 1 Baht", PromID: "B1N1", Selection: c.selection},
+			{PromName: "Buy A,B get C free", PromID: "B2I1", Selection: c.selection},
+			{PromName: "Buy 1 get next half", PromID: "B1NH", Selection: c.selection},
+		}}
+		result, err := order.Price()
+		if err != nil {
+			t.Fatalf("%q: expected no error, got %v", c.selection, err)
+		}
+		expected := []float64{c.b2g1, c.b1n1, c.b2i1, c.b1nh}
+		for i, discount := range expected {
+			if result.Breakdown[i].Discount != discount {
+				t.Errorf("%q: expected %s to be %f, got %f", c.selection, result.Breakdown[i].PromID, discount, result.Breakdown[i].Discount)
+			}
+		}
+	}
+	t.Run("Customer hasn't chosen an item", func(t *testing.T) {
+		order := Order{ID: "1", Items: []Item{
+			{SKU: "A", Price: 50, Amount: 3, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+		}, Promotions: []Promotion{
+			{PromName: "Buy2Get1Free", PromID: "B2G1", Selection: SelectCustomerChosen},
+		}}
+		order.CalcTotal()
+		order.CalcDiscount()
+		if order.Discount != 0 {
+			t.Errorf("Expected discount to be 0, got %f", order.Discount)
+		}
+	})
+	t.Run("First of the chosen items", func(t *testing.T) {
+		order := Order{ID: "1", Items: []Item{
+			{SKU: "A", Price: 50, Amount: 3, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false, Chosen: true},
+			{SKU: "B", Price: 80, Amount: 3, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false, Chosen: true},
+		}, Promotions: []Promotion{
+			{PromName: "Buy2Get1Free", PromID: "B2G1", Selection: SelectCustomerChosen},
+		}}
+		if result, _ := order.Price(); result.Discount != 50 {
+			t.Errorf("Expected SKU A to be free, got %f", result.Discount)
+		}
+	})
+	t.Run("Unknown selection", func(t *testing.T) {
+		order := Order{ID: "1", Promotions: []Promotion{{PromName: "Buy2Get1Free", PromID: "B2G1", Selection: "random"}}}
+		if err := order.Validate(); err == nil {
+			t.Errorf("Expected error for unknown selection")
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// MaxOrderBytes is the largest order the pricing server reads, larger bodies get 413 Request Entity Too Large
+const MaxOrderBytes = 1 << 20
+
+// NewPricingServer prices the orders posted as JSON to /price with the policy and serves the metrics of the pricing on /metrics.
+// Every order is priced with the metrics and the logger as its Observer
+func NewPricingServer(metrics *Metrics, logger *slog.Logger, policy PricingPolicy) http.Handler {
+	observer := PricingObservers{metrics, NewSlogObserver(logger)}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	mux.HandleFunc("/price", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "use POST with an order as JSON", http.StatusMethodNotAllowed)
+			return
+		}
+		var order Order
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxOrderBytes)).Decode(&order); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, fmt.Sprintf("order: larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, fmt.Sprintf("order: %v", err), http.StatusBadRequest)
+			return
+		}
+		order.Observer = observer
+		result, err := policy.Price(&order)
+		switch {
+		case errors.Is(err, ErrInvalidOrder):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		case errors.Is(err, ErrOrderFrozen):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+	return mux
+}
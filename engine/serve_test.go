@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPricingServer(t *testing.T) {
+	policy := PricingPolicy{Adjustments: AdjustmentPolicy{Limits: adjustmentLimits, Staff: adjustmentStaff, Interaction: Stack}}
+	server := httptest.NewServer(NewPricingServer(NewMetrics(), slog.New(slog.NewTextHandler(io.Discard, nil)), policy))
+	defer server.Close()
+
+	body := `{"ID": "S1", "Items": [{"SKU": "A", "Price": 100, "Amount": 3}], "Promotions": [{"PromName": "Buy 2 get 1 free", "PromID": "B2G1"}]}`
+	resp, err := http.Post(server.URL+"/price", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result PricingResult
+	json.NewDecoder(resp.Body).Decode(&result)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || result.Discount != 100 || result.Applied.PromID != "B2G1" {
+		t.Errorf("Expected 100 off with B2G1, got %d %+v", resp.StatusCode, result)
+	}
+
+	t.Run("Priced with the policy of the server", func(t *testing.T) {
+		body := `{"ID": "S3", "Items": [{"SKU": "A", "Price": 100, "Amount": 1}], "Adjustments": [{"Kind": "order_discount", "Amount": 10, "Reason": "GOODWILL", "StaffID": "S1"}]}`
+		resp, err := http.Post(server.URL+"/price", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result PricingResult
+		json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || result.Discount != 10 {
+			t.Errorf("Expected the goodwill of 10, got %d %+v", resp.StatusCode, result)
+		}
+	})
+	t.Run("Invalid order", func(t *testing.T) {
+		resp, err := http.Post(server.URL+"/price", "application/json", strings.NewReader(`{"ID": "S2", "Items": [{"SKU": "A", "Price": -1, "Amount": 1}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnprocessableEntity {
+			t.Errorf("Expected 422, got %d", resp.StatusCode)
+		}
+	})
+	t.Run("Order too large", func(t *testing.T) {
+		body := `{"ID": "S4", "Items": [` + strings.Repeat(`{"SKU": "A", "Price": 1, "Amount": 1},`, MaxOrderBytes/38) + `{"SKU": "A", "Price": 1, "Amount": 1}]}`
+		resp, err := http.Post(server.URL+"/price", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Errorf("Expected 413, got %d", resp.StatusCode)
+		}
+	})
+	t.Run("Metrics", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		for _, want := range []string{`altpromotions_promotions_applied_total{prom_id="B2G1"} 1`, `altpromotions_pricing_errors_total{kind="invalid_order"} 1`} {
+			if !strings.Contains(string(out), want) {
+				t.Errorf("Expected %s, got\n%s", want, out)
+			}
+		}
+	})
+}
@@ -0,0 +1,222 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SimulationReport is what a promotion set would have cost over a corpus of past orders
+type SimulationReport struct {
+	Orders          int     // Number of orders replayed
+	Redeemed        int     // Orders where a promotion gave a discount
+	Basket          float64 // Sum of order totals before discount
+	TotalDiscount   float64
+	RedemptionRate  float64                    // Redeemed / Orders
+	AvgBasketChange float64                    // Average change of the payable amount per order in percent, -10 means customers paid 10% less
+	ByPromotion     map[string]*PromotionStats // Keyed by PromID, only the winning promotion of an order is counted
+}
+
+type PromotionStats struct {
+	PromName string
+	Wins     int
+	Discount float64
+}
+
+// Simulate replays the orders through the promotion set and reports the discount that would have been given.
+// With a nil promotion set every order is priced with its own promotions, which is the current set that CalcDiscount uses.
+// Every order is priced with the policy, so adjustments, budgets and rounding are replayed the same way they are given.
+// The first error of an order is returned with the report of the orders that could be priced
+func Simulate(orders []Order, promotions []Promotion, policy PricingPolicy) (SimulationReport, error) {
+	report := SimulationReport{ByPromotion: make(map[string]*PromotionStats)}
+	in := make(chan Order)
+	go func() {
+		defer close(in)
+		for _, order := range orders {
+			in <- order
+		}
+	}()
+	var basketChange float64
+	var err error
+	for result := range PriceBatch(context.Background(), in, BatchOptions{Promotions: promotions, Pricing: policy}) {
+		if result.Err != nil {
+			if err == nil {
+				err = result.Err
+			}
+			continue
+		}
+		order := result.Order
+		report.Orders++
+		report.Basket += order.Total
+		report.TotalDiscount += order.Discount
+		if order.Total > 0 {
+			basketChange -= order.Discount / order.Total * 100
+		}
+		// The pricing records the winning promotion, evaluating the order again would reprice it
+		best := order.Applied
+		if best.PromID == "" {
+			continue
+		}
+		report.Redeemed++
+		stats := report.ByPromotion[best.PromID]
+		if stats == nil {
+			stats = &PromotionStats{PromName: best.PromName}
+			report.ByPromotion[best.PromID] = stats
+		}
+		stats.Wins++
+		stats.Discount += best.Discount
+	}
+	if report.Orders > 0 {
+		report.RedemptionRate = float64(report.Redeemed) / float64(report.Orders)
+		report.AvgBasketChange = basketChange / float64(report.Orders)
+	}
+	return report, err
+}
+
+func (report SimulationReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "Orders: %d\n", report.Orders)
+	fmt.Fprintf(w, "Basket: %.2f\n", report.Basket)
+	fmt.Fprintf(w, "Total Discount: %.2f\n", report.TotalDiscount)
+	fmt.Fprintf(w, "Redemption Rate: %.2f%%\n", report.RedemptionRate*100)
+	fmt.Fprintf(w, "Average Basket Change: %.2f%%\n", report.AvgBasketChange)
+	ids := make([]string, 0, len(report.ByPromotion))
+	for id := range report.ByPromotion {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		stats := report.ByPromotion[id]
+		var share float64
+		if report.TotalDiscount > 0 {
+			share = stats.Discount / report.TotalDiscount * 100
+		}
+		fmt.Fprintf(w, "  %s %s: %d orders, %.2f discount (%.2f%%)\n", id, stats.PromName, stats.Wins, stats.Discount, share)
+	}
+}
+
+// PrintComparison shows the candidate against the current set, positive numbers mean the candidate costs more
+func PrintComparison(w io.Writer, current, candidate SimulationReport) {
+	fmt.Fprintf(w, "Total Discount: %+.2f\n", candidate.TotalDiscount-current.TotalDiscount)
+	fmt.Fprintf(w, "Redemption Rate: %+.2f%%\n", (candidate.RedemptionRate-current.RedemptionRate)*100)
+	fmt.Fprintf(w, "Average Basket Change: %+.2f%%\n", candidate.AvgBasketChange-current.AvgBasketChange)
+}
+
+// LoadOrders reads a corpus of past orders. The format is "csv" or "ndjson".
+// CSV has one item per row with the header order_id,sku,price,amount,valid_selected_item,valid_free_item,valid_fifty_off
+// and an optional unit column. The amount of a row with a unit is the weighed quantity, like 0.75.
+// Rows of the same order_id are grouped into one order. NDJSON has one Order per line.
+func LoadOrders(r io.Reader, format string) ([]Order, error) {
+	switch format {
+	case "csv":
+		return readCSVOrders(r)
+	case "ndjson", "jsonl":
+		return readNDJSONOrders(r)
+	}
+	return nil, fmt.Errorf("unknown order format %q", format)
+}
+
+func readCSVOrders(r io.Reader) ([]Order, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading csv header: %w", err)
+	}
+	if len(header) != 7 && len(header) != 8 {
+		return nil, fmt.Errorf("csv header has %d columns, expected 7 or 8", len(header))
+	}
+	var orders []Order
+	index := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return orders, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		item := Item{SKU: record[1]}
+		if item.Price, err = strconv.ParseFloat(record[2], 64); err != nil {
+			return nil, fmt.Errorf("line %d: price: %w", line, err)
+		}
+		if len(record) == 8 {
+			item.Unit = Unit(record[7])
+		}
+		if item.Weighted() {
+			item.Quantity, err = strconv.ParseFloat(record[3], 64)
+		} else {
+			item.Amount, err = strconv.ParseInt(record[3], 10, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: amount: %w", line, err)
+		}
+		flags := []*bool{&item.ValidSelectedItem, &item.ValidFreeItem, &item.ValidFiftyOff}
+		for i, flag := range flags {
+			if record[4+i] == "" {
+				continue
+			}
+			if *flag, err = strconv.ParseBool(record[4+i]); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+		i, ok := index[record[0]]
+		if !ok {
+			i = len(orders)
+			index[record[0]] = i
+			orders = append(orders, Order{ID: record[0]})
+		}
+		orders[i].Items = append(orders[i].Items, item)
+	}
+}
+
+func readNDJSONOrders(r io.Reader) ([]Order, error) {
+	var orders []Order
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var order Order
+		if err := json.Unmarshal(scanner.Bytes(), &order); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		orders = append(orders, order)
+	}
+	return orders, scanner.Err()
+}
+
+// ParsePromotions reads a promotion set from a comma separated list of PromIDs or from a JSON file of Promotions when it ends with .json
+func ParsePromotions(spec string) ([]Promotion, error) {
+	if strings.HasSuffix(spec, ".json") {
+		data, err := os.ReadFile(spec)
+		if err != nil {
+			return nil, err
+		}
+		var promotions []Promotion
+		if err := json.Unmarshal(data, &promotions); err != nil {
+			return nil, fmt.Errorf("%s: %w", spec, err)
+		}
+		return promotions, nil
+	}
+	var promotions []Promotion
+	for _, id := range strings.Split(spec, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			promotions = append(promotions, Promotion{PromName: id, PromID: id})
+		}
+	}
+	if len(promotions) == 0 {
+		return nil, errors.New("empty promotion set")
+	}
+	return promotions, nil
+}
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const simulateCSV = `order_id,sku,price,amount,valid_selected_item,valid_free_item,valid_fifty_off
+1,A,500,3,false,false,false
+1,B,100,1,false,false,false
+2,A,200,1,,,
+3,C,1000,1,false,false,false
+`
+
+func TestLoadOrders(t *testing.T) {
+	t.Run("CSV rows are grouped by order", func(t *testing.T) {
+		orders, err := LoadOrders(strings.NewReader(simulateCSV), "csv")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(orders) != 3 || len(orders[0].Items) != 2 || orders[0].Items[0].Amount != 3 {
+			t.Errorf("Expected 3 orders with 2 items in the first, got %v", orders)
+		}
+	})
+	t.Run("NDJSON", func(t *testing.T) {
+		ndjson := `{"ID":"1","Items":[{"SKU":"A","Price":500,"Amount":3}],"Promotions":[{"PromName":"50% Off","PromID":"HOFF"}]}
+
+{"ID":"2","Items":[{"SKU":"A","Price":200,"Amount":1}]}
+`
+		orders, err := LoadOrders(strings.NewReader(ndjson), "ndjson")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if len(orders) != 2 || orders[0].Promotions[0].PromID != "HOFF" {
+			t.Errorf("Expected 2 orders with HOFF on the first, got %v", orders)
+		}
+	})
+	t.Run("Invalid price", func(t *testing.T) {
+		_, err := LoadOrders(strings.NewReader("order_id,sku,price,amount,a,b,c\n1,A,abc,1,,,\n"), "csv")
+		if err == nil {
+			t.Errorf("Expected error for invalid price")
+		}
+	})
+}
+
+func TestSimulate(t *testing.T) {
+	orders, _ := LoadOrders(strings.NewReader(simulateCSV), "csv")
+	report, err := Simulate(orders, []Promotion{
+		{PromName: "Buy2Get1Free", PromID: "B2G1"},
+		{PromName: "100 Baht off over 1000", PromID: "D100"},
+	}, PricingPolicy{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// Order 1 gets 500 off with Buy2Get1Free, order 3 gets 100 off and order 2 doesn't get any discount
+	if report.Orders != 3 || report.Redeemed != 2 || report.TotalDiscount != 600 {
+		t.Errorf("Expected 3 orders, 2 redeemed and 600 discount, got %d, %d and %f", report.Orders, report.Redeemed, report.TotalDiscount)
+	}
+	if report.ByPromotion["B2G1"].Wins != 1 || report.ByPromotion["D100"].Discount != 100 {
+		t.Errorf("Expected 1 win for B2G1 and 100 discount for D100, got %v and %v", report.ByPromotion["B2G1"], report.ByPromotion["D100"])
+	}
+	// (500/1600 + 0 + 100/1000) / 3 = 13.75%
+	if report.AvgBasketChange > -13.74 || report.AvgBasketChange < -13.76 {
+		t.Errorf("Expected average basket change to be -13.75, got %f", report.AvgBasketChange)
+	}
+
+	// The orders in the CSV don't have promotions so the current set doesn't give any discount
+	current, _ := Simulate(orders, nil, PricingPolicy{})
+	var out bytes.Buffer
+	PrintComparison(&out, current, report)
+	if !strings.Contains(out.String(), "Total Discount: +600.00") {
+		t.Errorf("Expected +600 total discount in comparison, got %s", out.String())
+	}
+}
+
+func TestSimulatePaidOrders(t *testing.T) {
+	// Past orders are paid or refunded, their pricing is frozen but they are still replayed
+	ndjson := `{"ID":"1","State":"paid","Items":[{"SKU":"A","Price":500,"Amount":3}],"Total":1500,"Discount":500}
+{"ID":"2","State":"refunded","Items":[{"SKU":"A","Price":200,"Amount":1}],"Total":200,"Refunded":200}
+`
+	orders, err := LoadOrders(strings.NewReader(ndjson), "ndjson")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	report, err := Simulate(orders, []Promotion{{PromName: "50% Off", PromID: "HOFF"}}, PricingPolicy{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.Orders != 2 || report.Redeemed != 2 || report.TotalDiscount != 850 {
+		t.Errorf("Expected 2 orders, 2 redeemed and 850 discount, got %d, %d and %f", report.Orders, report.Redeemed, report.TotalDiscount)
+	}
+}
@@ -0,0 +1,387 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLiteOrderRepository stores orders in SQLite. Items have their own table with one row per line, promotions are stored
+// with their PromID and the whole configuration as JSON, the breakdown of the last pricing has a row per promotion, manual adjustments, the adjustments given and the payment are stored as JSON on the order
+// and the sales context has a column for each field. CreatedAt is stored as Unix nanoseconds in UTC for the date range queries.
+type SQLiteOrderRepository struct {
+	db *sql.DB
+}
+
+// sqliteSchema is the schema of the first version, later columns are added by the migrations
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS orders (
+	id          TEXT PRIMARY KEY,
+	customer_id TEXT NOT NULL,
+	created_at  INTEGER NOT NULL,
+	total       REAL NOT NULL,
+	discount    REAL NOT NULL
+);
+CREATE INDEX IF NOT EXISTS orders_customer ON orders (customer_id, created_at);
+CREATE INDEX IF NOT EXISTS orders_created ON orders (created_at);
+CREATE TABLE IF NOT EXISTS order_items (
+	order_id            TEXT NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+	line                INTEGER NOT NULL,
+	sku                 TEXT NOT NULL,
+	price               REAL NOT NULL,
+	amount              INTEGER NOT NULL,
+	valid_selected_item INTEGER NOT NULL,
+	valid_free_item     INTEGER NOT NULL,
+	valid_fifty_off     INTEGER NOT NULL,
+	PRIMARY KEY (order_id, line)
+);
+CREATE TABLE IF NOT EXISTS order_promotions (
+	order_id TEXT NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+	position INTEGER NOT NULL,
+	prom_id  TEXT NOT NULL,
+	config   TEXT NOT NULL,
+	PRIMARY KEY (order_id, position)
+);
+`
+
+// sqliteMigrations take the database from the version of their index to the next one, the version is kept in PRAGMA user_version.
+// Databases of builds before the migrations are at version 0 with some of the later columns already added,
+// so the tables are created only if they don't exist and addColumns skips the columns the table already has.
+// New migrations are only ever appended
+var sqliteMigrations = []func(tx *sql.Tx) error{
+	// 1: orders with their items and promotions
+	func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqliteSchema)
+		return err
+	},
+	// 2: lifecycle and refunds
+	addColumns("orders", "state TEXT NOT NULL DEFAULT ''", "refunded REAL NOT NULL DEFAULT 0"),
+	// 3: items sold by weight or volume
+	addColumns("order_items", "unit TEXT NOT NULL DEFAULT ''", "quantity REAL NOT NULL DEFAULT 0"),
+	// 4: manual adjustments
+	addColumns("orders", "adjustments TEXT NOT NULL DEFAULT 'null'"),
+	// 5: units chosen by the customer
+	addColumns("order_items", "chosen INTEGER NOT NULL DEFAULT 0"),
+	// 6: sales context
+	addColumns("orders", "channel TEXT NOT NULL DEFAULT ''", "store_id TEXT NOT NULL DEFAULT ''", "region TEXT NOT NULL DEFAULT ''", "zone TEXT NOT NULL DEFAULT ''"),
+	// 7: referrals
+	addColumns("orders", "referral TEXT NOT NULL DEFAULT ''"),
+	// 8: payment
+	addColumns("orders", "payment TEXT NOT NULL DEFAULT '{}'"),
+	// 9: cash rounding
+	addColumns("orders", "rounding REAL NOT NULL DEFAULT 0"),
+	// 10: receipt locale
+	addColumns("orders", "locale TEXT NOT NULL DEFAULT ''"),
+	// 11: the applied promotion and the discount of every promotion at the last pricing
+	func(tx *sql.Tx) error {
+		if err := addColumns("orders", "applied_prom_id TEXT NOT NULL DEFAULT ''")(tx); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+CREATE INDEX IF NOT EXISTS orders_applied ON orders (applied_prom_id);
+CREATE TABLE IF NOT EXISTS order_breakdown (
+	order_id  TEXT NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+	position  INTEGER NOT NULL,
+	prom_id   TEXT NOT NULL,
+	prom_name TEXT NOT NULL,
+	discount  REAL NOT NULL,
+	reason    TEXT NOT NULL,
+	applied   INTEGER NOT NULL,
+	PRIMARY KEY (order_id, position)
+);`)
+		return err
+	},
+	// 12: the adjustments given at the last pricing
+	addColumns("orders", "given TEXT NOT NULL DEFAULT 'null'"),
+}
+
+// addColumns adds the columns that the table doesn't have yet. Columns are given as their definition, like "rounding REAL NOT NULL DEFAULT 0"
+func addColumns(table string, columns ...string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		rows, err := tx.Query(`PRAGMA table_info(` + table + `)`)
+		if err != nil {
+			return err
+		}
+		existing := make(map[string]bool)
+		for rows.Next() {
+			var cid, notNull, pk int
+			var name, kind string
+			var value sql.NullString
+			if err := rows.Scan(&cid, &name, &kind, &notNull, &value, &pk); err != nil {
+				rows.Close()
+				return err
+			}
+			existing[name] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for _, column := range columns {
+			if existing[strings.Fields(column)[0]] {
+				continue
+			}
+			if _, err := tx.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// migrateSQLite runs the migrations the database hasn't had yet, each in its own transaction with the new version
+func migrateSQLite(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	if version > len(sqliteMigrations) {
+		return fmt.Errorf("database is at version %d, this build knows up to version %d", version, len(sqliteMigrations))
+	}
+	for ; version < len(sqliteMigrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := sqliteMigrations[version](tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OpenSQLiteOrderRepository opens the database at the path and migrates it to the schema of this build, creating the tables
+// of a new database. ":memory:" is an in-memory database
+func OpenSQLiteOrderRepository(path string) (*SQLiteOrderRepository, error) {
+	db, err := sql.Open("sqlite3", path+"?_foreign_keys=on")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer and every connection to :memory: is a new database
+	db.SetMaxOpenConns(1)
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating schema: %w", err)
+	}
+	return &SQLiteOrderRepository{db: db}, nil
+}
+
+func (repo *SQLiteOrderRepository) Close() error {
+	return repo.db.Close()
+}
+
+func (repo *SQLiteOrderRepository) Save(ctx context.Context, order Order) (err error) {
+	if order.ID == "" {
+		return errors.New("order: ID is required to save an order")
+	}
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	// Replacing the order row cascades to the items and promotions of the previous save
+	if _, err = tx.ExecContext(ctx, `DELETE FROM orders WHERE id = ?`, order.ID); err != nil {
+		return err
+	}
+	adjustments, err := json.Marshal(order.Adjustments)
+	if err != nil {
+		return err
+	}
+	given, err := json.Marshal(order.Given)
+	if err != nil {
+		return err
+	}
+	payment, err := json.Marshal(order.Payment)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO orders (id, customer_id, created_at, total, discount, state, refunded, adjustments, channel, store_id, region, zone, referral, payment, rounding, locale, applied_prom_id, given) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		order.ID, order.CustomerID, unixNano(order.CreatedAt), order.Total, order.Discount, string(order.State), order.Refunded, string(adjustments),
+		string(order.Context.Channel), order.Context.StoreID, order.Context.Region, order.Context.Zone, order.ReferralCode, string(payment), order.Rounding, string(order.Locale),
+		order.Applied.PromID, string(given))
+	if err != nil {
+		return err
+	}
+	for i, item := range order.Items {
+		_, err = tx.ExecContext(ctx, `INSERT INTO order_items (order_id, line, sku, price, amount, unit, quantity, valid_selected_item, valid_free_item, valid_fifty_off, chosen) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			order.ID, i, item.SKU, item.Price, item.Amount, string(item.Unit), item.Quantity, item.ValidSelectedItem, item.ValidFreeItem, item.ValidFiftyOff, item.Chosen)
+		if err != nil {
+			return err
+		}
+	}
+	for i, prom := range order.Promotions {
+		var config []byte
+		if config, err = json.Marshal(prom); err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, `INSERT INTO order_promotions (order_id, position, prom_id, config) VALUES (?, ?, ?, ?)`, order.ID, i, prom.PromID, string(config)); err != nil {
+			return err
+		}
+	}
+	// The applied promotion is flagged on its row of the breakdown, the first one like Best picks it
+	applied := -1
+	for i, result := range order.Breakdown {
+		if order.Applied.PromID != "" && result == order.Applied {
+			applied = i
+			break
+		}
+	}
+	for i, result := range order.Breakdown {
+		_, err = tx.ExecContext(ctx, `INSERT INTO order_breakdown (order_id, position, prom_id, prom_name, discount, reason, applied) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			order.ID, i, result.PromID, result.PromName, result.Discount, result.Reason, i == applied)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (repo *SQLiteOrderRepository) Get(ctx context.Context, id string) (Order, error) {
+	orders, err := repo.query(ctx, `WHERE id = ?`, id)
+	if err != nil {
+		return Order{}, err
+	}
+	if len(orders) == 0 {
+		return Order{}, ErrOrderNotFound
+	}
+	return orders[0], nil
+}
+
+func (repo *SQLiteOrderRepository) ByCustomer(ctx context.Context, customerID string) ([]Order, error) {
+	return repo.query(ctx, `WHERE customer_id = ?`, customerID)
+}
+
+func (repo *SQLiteOrderRepository) ByDateRange(ctx context.Context, from, to time.Time) ([]Order, error) {
+	return repo.query(ctx, `WHERE created_at >= ? AND created_at < ? AND created_at <> 0`, unixNano(from), unixNano(to))
+}
+
+// unixNano is how created_at is stored. The zero time is stored as 0 since its UnixNano overflows
+// and would load back as a date in 1754
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// query loads the orders matching the where clause with their items and promotions
+func (repo *SQLiteOrderRepository) query(ctx context.Context, where string, args ...interface{}) ([]Order, error) {
+	rows, err := repo.db.QueryContext(ctx, `SELECT id, customer_id, created_at, total, discount, state, refunded, adjustments, channel, store_id, region, zone, referral, payment, rounding, locale, given FROM orders `+where+` ORDER BY created_at, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	var orders []Order
+	for rows.Next() {
+		var order Order
+		var createdAt int64
+		var adjustments, given, payment string
+		if err := rows.Scan(&order.ID, &order.CustomerID, &createdAt, &order.Total, &order.Discount, &order.State, &order.Refunded, &adjustments,
+			&order.Context.Channel, &order.Context.StoreID, &order.Context.Region, &order.Context.Zone, &order.ReferralCode, &payment, &order.Rounding, &order.Locale, &given); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(adjustments), &order.Adjustments); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("order %s: adjustments: %w", order.ID, err)
+		}
+		if err := json.Unmarshal([]byte(given), &order.Given); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("order %s: given: %w", order.ID, err)
+		}
+		if err := json.Unmarshal([]byte(payment), &order.Payment); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("order %s: payment: %w", order.ID, err)
+		}
+		if createdAt != 0 {
+			order.CreatedAt = time.Unix(0, createdAt).UTC()
+		}
+		orders = append(orders, order)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// The connection pool has a single connection so the lines are loaded after the orders are read
+	for i := range orders {
+		if err := repo.loadLines(ctx, &orders[i]); err != nil {
+			return nil, err
+		}
+	}
+	return orders, nil
+}
+
+func (repo *SQLiteOrderRepository) loadLines(ctx context.Context, order *Order) error {
+	rows, err := repo.db.QueryContext(ctx, `SELECT sku, price, amount, unit, quantity, valid_selected_item, valid_free_item, valid_fifty_off, chosen FROM order_items WHERE order_id = ? ORDER BY line`, order.ID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var item Item
+		if err := rows.Scan(&item.SKU, &item.Price, &item.Amount, &item.Unit, &item.Quantity, &item.ValidSelectedItem, &item.ValidFreeItem, &item.ValidFiftyOff, &item.Chosen); err != nil {
+			rows.Close()
+			return err
+		}
+		order.Items = append(order.Items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = repo.db.QueryContext(ctx, `SELECT config FROM order_promotions WHERE order_id = ? ORDER BY position`, order.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var config string
+		if err := rows.Scan(&config); err != nil {
+			return err
+		}
+		var prom Promotion
+		if err := json.Unmarshal([]byte(config), &prom); err != nil {
+			return fmt.Errorf("order %s: promotion: %w", order.ID, err)
+		}
+		order.Promotions = append(order.Promotions, prom)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	rows, err = repo.db.QueryContext(ctx, `SELECT prom_id, prom_name, discount, reason, applied FROM order_breakdown WHERE order_id = ? ORDER BY position`, order.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var result PromotionResult
+		var applied bool
+		if err := rows.Scan(&result.PromID, &result.PromName, &result.Discount, &result.Reason, &applied); err != nil {
+			return err
+		}
+		if applied {
+			order.Applied = result
+		}
+		order.Breakdown = append(order.Breakdown, result)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,32 @@
+package engine
+
+// Unit is the unit of measure of an item's Price
+type Unit string
+
+const (
+	UnitEach  Unit = ""
+	UnitKg    Unit = "kg"
+	UnitGram  Unit = "g"
+	UnitLitre Unit = "l"
+)
+
+func (unit Unit) Valid() bool {
+	switch unit {
+	case UnitEach, UnitKg, UnitGram, UnitLitre:
+		return true
+	}
+	return false
+}
+
+// Weighted reports if the item is sold by weight or volume instead of per piece
+func (item Item) Weighted() bool {
+	return item.Unit != UnitEach
+}
+
+// Qty is the quantity the Price is multiplied by, the Quantity for weighted items and the Amount for the rest
+func (item Item) Qty() float64 {
+	if item.Weighted() {
+		return item.Quantity
+	}
+	return float64(item.Amount)
+}
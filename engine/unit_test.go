@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWeightedItems(t *testing.T) {
+	t.Run("Total uses the quantity of weighted items", func(t *testing.T) {
+		order := Order{
+			ID: "1",
+			Items: []Item{
+				{SKU: "Apple", Price: 80, Unit: UnitKg, Quantity: 1.5},
+				{SKU: "B", Price: 30, Amount: 2, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+			},
+		}
+		order.CalcTotal()
+		// 1.5 kg at 80 Baht per kg plus 2 of SKU B
+		if order.Total != 180 {
+			t.Errorf("Expected total 180, got %f", order.Total)
+		}
+	})
+	t.Run("20% off per kg", func(t *testing.T) {
+		order := Order{
+			ID: "1",
+			Items: []Item{
+				{SKU: "Apple", Price: 80, Unit: UnitKg, Quantity: 2.5},
+				{SKU: "Cheese", Price: 50, Unit: UnitKg, Quantity: 0.5},
+				{SKU: "B", Price: 30, Amount: 2, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+			},
+			Promotions: []Promotion{
+				{PromName: "20% off produce", PromID: "WPCT", Percent: 20},
+			},
+		}
+		result, err := order.Price()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		// 20% of 200 + 25 Baht of weighted items, SKU B is sold per piece so it isn't discounted
+		if result.Discount != 45 {
+			t.Errorf("Expected discount 45, got %f", result.Discount)
+		}
+	})
+	t.Run("Weighted items are not counted as units", func(t *testing.T) {
+		order := Order{
+			ID: "1",
+			Items: []Item{
+				{SKU: "Apple", Price: 80, Unit: UnitKg, Quantity: 3},
+				{SKU: "B", Price: 30, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+			},
+			Promotions: []Promotion{
+				{PromName: "Buy2Get1Free", PromID: "B2G1"},
+				{PromName: "Buy 1 get next 1 Baht", PromID: "B1N1"},
+				{PromName: "1 15%, 2 20%, 3 30%", PromID: "INCD"},
+			},
+		}
+		result, _ := order.Price()
+		// 3 kg of apples isn't 3 units, B2G1 and B1N1 aren't applicable and INCD counts 2 items for 20% of 270
+		if result.Breakdown[0].Discount != 0 || result.Breakdown[1].Discount != 0 || result.Breakdown[2].Discount != 54 {
+			t.Errorf("Expected 0, 0 and 54, got %v", result.Breakdown)
+		}
+	})
+	t.Run("Validation", func(t *testing.T) {
+		order := Order{
+			ID: "1",
+			Items: []Item{
+				{SKU: "Apple", Price: 80, Unit: UnitKg, Quantity: 0, Amount: 1},
+				{SKU: "B", Price: 30, Amount: 1, Quantity: 0.5},
+				{SKU: "C", Price: 30, Unit: "lb", Quantity: 1},
+			},
+			Promotions: []Promotion{
+				{PromName: "20% off produce", PromID: "WPCT"},
+				{PromName: "50% Off", PromID: "HOFF", Percent: 150},
+			},
+		}
+		err := order.Validate()
+		for _, field := range []string{"Items[0].Quantity", "Items[0].Amount", "Items[1].Quantity", "Items[2].Unit", "Promotions[0].Percent", "Promotions[1].Percent"} {
+			if err == nil || !strings.Contains(err.Error(), field) {
+				t.Errorf("Expected error on %s, got %v", field, err)
+			}
+		}
+	})
+	t.Run("Cart reweighs weighted items", func(t *testing.T) {
+		cart := NewCart("1", []Promotion{{PromName: "20% off produce", PromID: "WPCT", Percent: 20}})
+		cart.AddItem(Item{SKU: "Apple", Price: 80, Unit: UnitKg, Quantity: 0.5})
+		cart.AddItem(Item{SKU: "Apple", Price: 80, Unit: UnitKg, Quantity: 0.75})
+		if cart.Order.Total != 100 || cart.Order.Discount != 20 {
+			t.Errorf("Expected total 100 and discount 20, got %f and %f", cart.Order.Total, cart.Order.Discount)
+		}
+		cart.SetWeight(0, 2)
+		if cart.Order.Total != 160 || cart.Order.Discount != 32 {
+			t.Errorf("Expected total 160 and discount 32, got %f and %f", cart.Order.Total, cart.Order.Discount)
+		}
+		if err := cart.SetQuantity(0, 3); err == nil {
+			t.Errorf("Expected error for setting the amount of a weighted item")
+		}
+	})
+	t.Run("CSV with a unit column", func(t *testing.T) {
+		csv := "order_id,sku,price,amount,valid_selected_item,valid_free_item,valid_fifty_off,unit\n1,Apple,80,0.75,,,,kg\n1,B,30,2,,,,\n"
+		orders, err := LoadOrders(strings.NewReader(csv), "csv")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if orders[0].Items[0].Quantity != 0.75 || orders[0].Items[1].Amount != 2 {
+			t.Errorf("Expected 0.75 kg of apples and 2 of SKU B, got %v", orders[0].Items)
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ValidationError is a single problem with a field of an order. Field is the path of the field in the order, like Items[2].Price
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (err ValidationError) Error() string {
+	return err.Field + ": " + err.Reason
+}
+
+// ValidationErrors is every problem found in an order so they can be fixed at once instead of one by one
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return "invalid order: " + strings.Join(messages, "; ")
+}
+
+// ErrInvalidOrder matches ValidationErrors with errors.Is
+var ErrInvalidOrder = errors.New("invalid order")
+
+func (errs ValidationErrors) Is(target error) bool {
+	return target == ErrInvalidOrder
+}
+
+// Validate checks the items and the promotions of the order. The error is ValidationErrors when the order is invalid
+func (order *Order) Validate() error {
+	errs := validateItems(order.Items)
+	if !order.Context.Channel.Valid() {
+		errs = append(errs, ValidationError{Field: "Context.Channel", Reason: fmt.Sprintf("unknown channel %q", order.Context.Channel)})
+	}
+	errs = append(errs, validatePayment("Payment", order.Payment)...)
+	if !order.Locale.Valid() {
+		errs = append(errs, ValidationError{Field: "Locale", Reason: fmt.Sprintf("unknown locale %q", order.Locale)})
+	}
+	errs = append(errs, validatePromotions(order.Promotions)...)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ValidateItems checks the items without the promotions, which is all CalcTotal needs
+func ValidateItems(items []Item) error {
+	if errs := validateItems(items); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateItems rejects lines that can't be priced. Duplicate lines of the same SKU are allowed since they are merged by Normalize
+func validateItems(items []Item) ValidationErrors {
+	var errs ValidationErrors
+	for i, item := range items {
+		errs = append(errs, validateItem(fmt.Sprintf("Items[%d]", i), item)...)
+	}
+	return errs
+}
+
+func validateItem(field string, item Item) ValidationErrors {
+	var errs ValidationErrors
+	if strings.TrimSpace(item.SKU) == "" {
+		errs = append(errs, ValidationError{Field: field + ".SKU", Reason: "must not be empty"})
+	}
+	if math.IsNaN(item.Price) || math.IsInf(item.Price, 0) {
+		errs = append(errs, ValidationError{Field: field + ".Price", Reason: "must be a number"})
+	} else if item.Price < 0 {
+		errs = append(errs, ValidationError{Field: field + ".Price", Reason: fmt.Sprintf("must not be negative, got %.2f", item.Price)})
+	}
+	switch {
+	case !item.Unit.Valid():
+		errs = append(errs, ValidationError{Field: field + ".Unit", Reason: fmt.Sprintf("unknown unit %q", item.Unit)})
+	case item.Weighted():
+		if !(item.Quantity > 0) || math.IsInf(item.Quantity, 0) {
+			errs = append(errs, ValidationError{Field: field + ".Quantity", Reason: fmt.Sprintf("must be greater than 0, got %g", item.Quantity)})
+		}
+		if item.Amount != 0 {
+			errs = append(errs, ValidationError{Field: field + ".Amount", Reason: "must be 0 for weighted items, the Quantity is used"})
+		}
+	default:
+		if item.Amount <= 0 {
+			errs = append(errs, ValidationError{Field: field + ".Amount", Reason: fmt.Sprintf("must be greater than 0, got %d", item.Amount)})
+		}
+		if item.Quantity != 0 {
+			errs = append(errs, ValidationError{Field: field + ".Quantity", Reason: "must be 0 for items sold per piece, the Amount is used"})
+		}
+	}
+	return errs
+}
+
+func validatePromotions(promotions []Promotion) ValidationErrors {
+	var errs ValidationErrors
+	for i, prom := range promotions {
+		errs = append(errs, validatePromotion(fmt.Sprintf("Promotions[%d]", i), prom)...)
+	}
+	return errs
+}
+
+func validatePromotion(field string, prom Promotion) ValidationErrors {
+	var errs ValidationErrors
+	if _, ok := lookupRule(prom.PromID); !ok {
+		errs = append(errs, ValidationError{Field: field + ".PromID", Reason: fmt.Sprintf("unknown promotion %q", prom.PromID)})
+	}
+	if prom.Cap < 0 || math.IsNaN(prom.Cap) {
+		errs = append(errs, ValidationError{Field: field + ".Cap", Reason: fmt.Sprintf("must not be negative, got %.2f", prom.Cap)})
+	}
+	if !prom.Selection.Valid() {
+		errs = append(errs, ValidationError{Field: field + ".Selection", Reason: fmt.Sprintf("unknown selection %q", prom.Selection)})
+	}
+	if prom.Percent < 0 || prom.Percent > 100 || math.IsNaN(prom.Percent) {
+		errs = append(errs, ValidationError{Field: field + ".Percent", Reason: fmt.Sprintf("must be between 0 and 100, got %g", prom.Percent)})
+	} else if (prom.PromID == "WPCT" || prom.PromID == "PCTO") && prom.Percent == 0 {
+		errs = append(errs, ValidationError{Field: field + ".Percent", Reason: "is required for " + prom.PromID})
+	}
+	if prom.Value < 0 || math.IsNaN(prom.Value) {
+		errs = append(errs, ValidationError{Field: field + ".Value", Reason: fmt.Sprintf("must not be negative, got %.2f", prom.Value)})
+	} else if prom.PromID == "BAHT" && prom.Value == 0 {
+		errs = append(errs, ValidationError{Field: field + ".Value", Reason: "is required for BAHT"})
+	}
+	if prom.Customer.InactiveDays < 0 {
+		errs = append(errs, ValidationError{Field: field + ".Customer.InactiveDays", Reason: fmt.Sprintf("must not be negative, got %d", prom.Customer.InactiveDays)})
+	}
+	errs = append(errs, validateScope(field+".Scope", prom.Scope)...)
+	errs = append(errs, validatePaymentCondition(field+".Payment", prom.Payment)...)
+	errs = append(errs, validateLocales(field+".Names", prom.Names)...)
+	errs = append(errs, validateLocales(field+".Descriptions", prom.Descriptions)...)
+	return errs
+}
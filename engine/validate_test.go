@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	t.Run("Invalid order is rejected with every field", func(t *testing.T) {
+		order := Order{
+			ID: "1",
+			Items: []Item{
+				{SKU: "A", Price: -50, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+				{SKU: "", Price: 20, Amount: 0, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+				{SKU: "A", Price: -50, Amount: 2, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+			},
+			Promotions: []Promotion{
+				{PromName: "Unknown", PromID: "FREE"},
+			},
+			Discount: 10,
+		}
+		err := order.CalcDiscount()
+		var errs ValidationErrors
+		if !errors.As(err, &errs) || !errors.Is(err, ErrInvalidOrder) {
+			t.Fatalf("Expected ValidationErrors, got %v", err)
+		}
+		expected := []string{"Items[0].Price", "Items[1].SKU", "Items[1].Amount", "Items[2].Price", "Promotions[0].PromID"}
+		if len(errs) != len(expected) {
+			t.Fatalf("Expected %d errors, got %v", len(expected), errs)
+		}
+		for i, field := range expected {
+			if errs[i].Field != field {
+				t.Errorf("Expected error %d on %s, got %s", i, field, errs[i].Field)
+			}
+		}
+		// The discount isn't changed when the order is rejected
+		if order.Discount != 10 {
+			t.Errorf("Expected discount to stay 10, got %f", order.Discount)
+		}
+	})
+	t.Run("CalcTotal only checks the items", func(t *testing.T) {
+		order := Order{
+			ID: "1",
+			Items: []Item{
+				{SKU: "A", Price: 50, Amount: -2, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+			},
+			Total: 100,
+		}
+		if err := order.CalcTotal(); err == nil || order.Total != 100 {
+			t.Errorf("Expected error and total to stay 100, got %v and %f", err, order.Total)
+		}
+		order.Items[0].Amount = 2
+		order.Promotions = []Promotion{{PromName: "Unknown", PromID: "FREE"}}
+		if err := order.CalcTotal(); err != nil || order.Total != 100 {
+			t.Errorf("Expected no error with total 100, got %v and %f", err, order.Total)
+		}
+	})
+	t.Run("Same SKU on two lines is valid", func(t *testing.T) {
+		order := Order{
+			ID: "1",
+			Items: []Item{
+				{SKU: "A", Price: 50, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+				{SKU: "A", Price: 40, Amount: 1, ValidSelectedItem: false, ValidFreeItem: false, ValidFiftyOff: false},
+			},
+			Promotions: []Promotion{
+				{PromName: "Buy 1 get next 1 Baht", PromID: "B1N1"},
+			},
+		}
+		if _, err := order.Price(); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	})
+	t.Run("Negative cap", func(t *testing.T) {
+		order := Order{ID: "1", Promotions: []Promotion{{PromName: "50% Off", PromID: "HOFF", Cap: -1}}}
+		if err := order.Validate(); err == nil {
+			t.Errorf("Expected error for negative cap")
+		}
+	})
+}
@@ -1,3 +1,5 @@
 module shashwot2/altpromotions
 
-go 1.18
+go 1.21
+
+require github.com/mattn/go-sqlite3 v1.14.16
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"shashwot2/altpromotions/engine"
+)
+
+func runLint(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	promotionSpec := flags.String("promotions", "", "promotions as comma separated PromIDs or a .json file")
+	catalogPath := flags.String("catalog", "", "optional .json file of the items that are sold")
+	now := flags.String("now", "", "deploy time in RFC3339 for reporting expired promotions, defaults to the current time")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *promotionSpec == "" {
+		return errors.New("lint: -promotions is required")
+	}
+	promotions, err := engine.ParsePromotions(*promotionSpec)
+	if err != nil {
+		return err
+	}
+	opts := engine.LintOptions{Now: time.Now()}
+	if *now != "" {
+		if opts.Now, err = time.Parse(time.RFC3339, *now); err != nil {
+			return err
+		}
+	}
+	if *catalogPath != "" {
+		file, err := os.Open(*catalogPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if err := json.NewDecoder(file).Decode(&opts.Catalog); err != nil {
+			return fmt.Errorf("%s: %w", *catalogPath, err)
+		}
+	}
+	issues := engine.Lint(promotions, opts)
+	engine.PrintLint(w, issues)
+	if count := engine.LintErrors(issues); count > 0 {
+		return fmt.Errorf("%d lint errors", count)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+// Package promotest checks that promotion rules keep the invariants of the engine on random orders. It is for teams writing
+// their own promotions with engine.RegisterRule and is imported from their tests.
+// A failing order is shrunk to the smallest order that still fails so it can be turned into a normal test case.
+//
+//	func init() {
+//		engine.RegisterRule("MINE", myRule)
+//	}
+//
+//	func TestMyPromotion(t *testing.T) {
+//		if failure := promotest.CheckProperties([]engine.Promotion{{PromName: "My promotion", PromID: "MINE"}}, promotest.PropertyOptions{}); failure != nil {
+//			t.Fatal(failure)
+//		}
+//	}
+package promotest
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"shashwot2/altpromotions/engine"
+)
+
+// Property is an invariant that must hold for every valid order priced with the promotions
+type Property struct {
+	Name  string
+	Check func(order engine.Order, promotions []engine.Promotion, r *rand.Rand) error
+}
+
+// PropertyOptions controls the random orders. Runs defaults to 500 and the same Seed always generates the same orders
+type PropertyOptions struct {
+	Runs       int
+	Seed       int64
+	Properties []Property // Defaults to EngineProperties
+}
+
+// PropertyFailure is the smallest order found that breaks the property
+type PropertyFailure struct {
+	Property   string
+	Seed       int64
+	Run        int
+	Order      engine.Order // Shrunk order
+	Original   engine.Order // Order as it was generated
+	Promotions []engine.Promotion
+	Err        error
+}
+
+func (failure *PropertyFailure) Error() string {
+	return fmt.Sprintf("property %q failed on run %d with seed %d: %v\nshrunk order: %+v", failure.Property, failure.Run, failure.Seed, failure.Err, failure.Order.Items)
+}
+
+// propertyTolerance absorbs the float difference of adding the same prices in a different order
+const propertyTolerance = 1e-6
+
+// EngineProperties are the invariants every promotion must keep
+var EngineProperties = []Property{
+	{Name: "discount is never negative", Check: func(order engine.Order, promotions []engine.Promotion, r *rand.Rand) error {
+		result, err := order.Price()
+		if err != nil {
+			return err
+		}
+		for _, prom := range result.Breakdown {
+			if prom.Discount < 0 || math.IsNaN(prom.Discount) {
+				return fmt.Errorf("%s gave a discount of %f", prom.PromID, prom.Discount)
+			}
+		}
+		return nil
+	}},
+	{Name: "discount never exceeds total", Check: func(order engine.Order, promotions []engine.Promotion, r *rand.Rand) error {
+		result, err := order.Price()
+		if err != nil {
+			return err
+		}
+		for _, prom := range result.Breakdown {
+			if prom.Discount > result.Total+propertyTolerance {
+				return fmt.Errorf("%s gave a discount of %f on a total of %f", prom.PromID, prom.Discount, result.Total)
+			}
+		}
+		return nil
+	}},
+	{Name: "result doesn't depend on item order", Check: func(order engine.Order, promotions []engine.Promotion, r *rand.Rand) error {
+		// Selections that pick by position depend on the order of the items by design
+		for _, prom := range promotions {
+			if prom.Selection == engine.SelectFirstScanned || prom.Selection == engine.SelectCustomerChosen {
+				return nil
+			}
+		}
+		result, err := order.Price()
+		if err != nil {
+			return err
+		}
+		shuffled := copyOrder(order)
+		r.Shuffle(len(shuffled.Items), func(i, j int) { shuffled.Items[i], shuffled.Items[j] = shuffled.Items[j], shuffled.Items[i] })
+		shuffledResult, err := shuffled.Price()
+		if err != nil {
+			return err
+		}
+		for i := range result.Breakdown {
+			if math.Abs(result.Breakdown[i].Discount-shuffledResult.Breakdown[i].Discount) > propertyTolerance {
+				return fmt.Errorf("%s gave %f but %f with the items shuffled", result.Breakdown[i].PromID, result.Breakdown[i].Discount, shuffledResult.Breakdown[i].Discount)
+			}
+		}
+		return nil
+	}},
+	{Name: "adding promotions never lowers the discount", Check: func(order engine.Order, promotions []engine.Promotion, r *rand.Rand) error {
+		var previous float64
+		for i := 1; i <= len(promotions); i++ {
+			order.Promotions = promotions[:i]
+			result, err := order.Price()
+			if err != nil {
+				return err
+			}
+			if result.Discount < previous {
+				return fmt.Errorf("adding %s lowered the discount from %f to %f", promotions[i-1].PromID, previous, result.Discount)
+			}
+			previous = result.Discount
+		}
+		return nil
+	}},
+}
+
+// CheckProperties prices random orders with the promotions and returns the first property that fails, or nil when they all hold
+func CheckProperties(promotions []engine.Promotion, opts PropertyOptions) *PropertyFailure {
+	if opts.Runs <= 0 {
+		opts.Runs = 500
+	}
+	if opts.Properties == nil {
+		opts.Properties = EngineProperties
+	}
+	r := rand.New(rand.NewSource(opts.Seed))
+	for run := 0; run < opts.Runs; run++ {
+		order := GenerateOrder(r)
+		order.Promotions = promotions
+		for _, property := range opts.Properties {
+			// Every check gets its own source so shrinking can repeat the same shuffles
+			checkSeed := r.Int63()
+			check := func(order engine.Order) error {
+				return property.Check(copyOrder(order), promotions, rand.New(rand.NewSource(checkSeed)))
+			}
+			if err := check(order); err != nil {
+				shrunk := Shrink(order, func(candidate engine.Order) bool { return check(candidate) != nil })
+				return &PropertyFailure{
+					Property:   property.Name,
+					Seed:       opts.Seed,
+					Run:        run,
+					Order:      shrunk,
+					Original:   order,
+					Promotions: promotions,
+					Err:        check(shrunk),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+var generatedSKUs = []string{"A", "B", "C", "D", "E", "F"}
+
+// GenerateOrder returns a random valid order without promotions. SKUs repeat so some orders have duplicate lines,
+// and about one in five lines is a weighted item
+func GenerateOrder(r *rand.Rand) engine.Order {
+	order := engine.Order{ID: fmt.Sprint("gen-", r.Int63())}
+	lines := r.Intn(7)
+	for i := 0; i < lines; i++ {
+		item := engine.Item{
+			SKU:               generatedSKUs[r.Intn(len(generatedSKUs))],
+			Price:             float64(r.Intn(200000)) / 100,
+			ValidSelectedItem: r.Intn(3) == 0,
+			ValidFreeItem:     r.Intn(3) == 0,
+			ValidFiftyOff:     r.Intn(3) == 0,
+			Chosen:            r.Intn(5) == 0,
+		}
+		if r.Intn(5) == 0 {
+			item.Unit = engine.UnitKg
+			item.Quantity = float64(r.Intn(5000)+1) / 1000
+		} else {
+			item.Amount = int64(r.Intn(6) + 1)
+		}
+		order.Items = append(order.Items, item)
+	}
+	return order
+}
+
+// Shrink makes the order smaller while it still fails: lines are removed, amounts and quantities are lowered,
+// prices are rounded down and flags are cleared until none of the changes keeps the failure
+func Shrink(order engine.Order, fails func(engine.Order) bool) engine.Order {
+	for {
+		shrunk := false
+		for _, candidate := range shrinkCandidates(order) {
+			if candidate.Validate() == nil && fails(candidate) {
+				order = candidate
+				shrunk = true
+				break
+			}
+		}
+		if !shrunk {
+			return order
+		}
+	}
+}
+
+func shrinkCandidates(order engine.Order) []engine.Order {
+	var candidates []engine.Order
+	withItem := func(i int, change func(item *engine.Item)) {
+		candidate := copyOrder(order)
+		change(&candidate.Items[i])
+		if candidate.Items[i] != order.Items[i] {
+			candidates = append(candidates, candidate)
+		}
+	}
+	for i := range order.Items {
+		candidate := copyOrder(order)
+		candidate.Items = append(candidate.Items[:i], candidate.Items[i+1:]...)
+		candidates = append(candidates, candidate)
+	}
+	for i := range order.Items {
+		withItem(i, func(item *engine.Item) {
+			if item.Amount > 1 {
+				item.Amount--
+			}
+		})
+		withItem(i, func(item *engine.Item) {
+			if item.Quantity > 1 {
+				item.Quantity = math.Floor(item.Quantity / 2)
+			}
+		})
+		withItem(i, func(item *engine.Item) { item.Price = math.Floor(item.Price / 2) })
+		withItem(i, func(item *engine.Item) { item.Price = math.Floor(item.Price) })
+		withItem(i, func(item *engine.Item) {
+			item.ValidSelectedItem, item.ValidFreeItem, item.ValidFiftyOff, item.Chosen = false, false, false, false
+		})
+	}
+	return candidates
+}
+
+// copyOrder copies the lines and promotions so checks and candidates can change them without changing the order
+func copyOrder(order engine.Order) engine.Order {
+	order.Items = append([]engine.Item(nil), order.Items...)
+	order.Promotions = append([]engine.Promotion(nil), order.Promotions...)
+	return order
+}
@@ -0,0 +1,51 @@
+package promotest
+
+import (
+	"testing"
+
+	"shashwot2/altpromotions/engine"
+)
+
+// A broken rule that gives 10 Baht more than the total whenever an item of SKU C is in the order
+func init() {
+	engine.RegisterRule("TEST", func(prom engine.Promotion, order engine.Order) float64 {
+		for _, item := range order.Items {
+			if item.SKU == "C" {
+				return order.Total + 10
+			}
+		}
+		return 0
+	})
+}
+
+func TestEngineProperties(t *testing.T) {
+	promotions := []engine.Promotion{
+		{PromName: "Buy2Get1Free", PromID: "B2G1"},
+		{PromName: "50% Off", PromID: "HOFF"},
+		{PromName: "Buy 1 get next 1 Baht", PromID: "B1N1", Selection: engine.SelectCheapest},
+		{PromName: "100 Baht off over 1000", PromID: "D100"},
+		{PromName: "Buy A,B get C free", PromID: "B2I1"},
+		{PromName: "Buy 1 get next half", PromID: "B1NH"},
+		{PromName: "1 15%, 2 20%, 3 30%", PromID: "INCD", Cap: 500},
+		{PromName: "20% off produce", PromID: "WPCT", Percent: 20},
+		{PromName: "10% off", PromID: "PCTO", Percent: 10},
+		{PromName: "50 Baht off", PromID: "BAHT", Value: 50},
+	}
+	if failure := CheckProperties(promotions, PropertyOptions{Seed: 1}); failure != nil {
+		t.Fatal(failure)
+	}
+}
+
+func TestShrink(t *testing.T) {
+	failure := CheckProperties([]engine.Promotion{{PromName: "Broken", PromID: "TEST"}}, PropertyOptions{Seed: 7})
+	if failure == nil {
+		t.Fatal("Expected the broken rule to fail")
+	}
+	if failure.Property != "discount never exceeds total" {
+		t.Errorf("Expected the total property to fail, got %s", failure.Property)
+	}
+	// The smallest failing order is a single line of SKU C with a price of 0
+	if len(failure.Order.Items) != 1 || failure.Order.Items[0].SKU != "C" || failure.Order.Items[0].Price != 0 || failure.Order.Items[0].ValidFreeItem {
+		t.Errorf("Expected a single line of SKU C at 0 Baht, got %+v", failure.Order.Items)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"shashwot2/altpromotions/engine"
+)
+
+func runScenarios(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("scenarios", flag.ContinueOnError)
+	dir := flags.String("dir", filepath.Join("engine", "testdata", "scenarios"), "directory of scenario files")
+	update := flags.Bool("update", false, "write the actual results as the new golden results")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	scenarios, err := engine.LoadScenarios(*dir)
+	if err != nil {
+		return err
+	}
+	failures, err := engine.RunScenarios(scenarios, *update)
+	if err != nil {
+		return err
+	}
+	for _, failure := range failures {
+		fmt.Fprintf(w, "FAIL %s (%s)\n", failure.Name, failure.Path)
+		for _, diff := range failure.Diffs {
+			fmt.Fprintf(w, "    %s\n", diff)
+		}
+	}
+	fmt.Fprintf(w, "%d scenarios, %d failed\n", len(scenarios), len(failures))
+	if len(failures) > 0 {
+		return fmt.Errorf("%d scenarios failed", len(failures))
+	}
+	return nil
+}
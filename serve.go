@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"shashwot2/altpromotions/engine"
+)
+
+func runServe(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", ":8080", "address to listen on")
+	format := flags.String("log-format", "text", "log format, text or json")
+	debug := flags.Bool("debug", false, "log every evaluated promotion")
+	policyPath := flags.String("policy", "", "optional .json file of the adjustment and rounding policy the orders are priced with")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{}
+	if *debug {
+		opts.Level = slog.LevelDebug
+	}
+	var handler slog.Handler
+	switch *format {
+	case "text":
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case "json":
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("serve: unknown log format %q, use text or json", *format)
+	}
+	policy, err := readPolicy(*policyPath)
+	if err != nil {
+		return err
+	}
+	// Timeouts so slow or idle clients can't hold connections open
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           engine.NewPricingServer(engine.NewMetrics(), slog.New(handler), policy),
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+	fmt.Fprintf(w, "pricing on http://%s/price, metrics on http://%s/metrics\n", *addr, *addr)
+	return server.ListenAndServe()
+}
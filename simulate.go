@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"shashwot2/altpromotions/engine"
+)
+
+func runSimulate(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	ordersPath := flags.String("orders", "", "corpus of past orders (.csv or .ndjson)")
+	format := flags.String("format", "", "format of the corpus, taken from the file extension when empty")
+	candidateSpec := flags.String("candidate", "", "candidate promotions as comma separated PromIDs or a .json file")
+	currentSpec := flags.String("current", "", "current promotions to compare against, defaults to the promotions recorded on each order")
+	policyPath := flags.String("policy", "", "optional .json file of the adjustment and rounding policy the orders are priced with")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *ordersPath == "" || *candidateSpec == "" {
+		return errors.New("simulate: -orders and -candidate are required")
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(*ordersPath), ".")
+	}
+	file, err := os.Open(*ordersPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	orders, err := engine.LoadOrders(file, *format)
+	if err != nil {
+		return err
+	}
+	candidate, err := engine.ParsePromotions(*candidateSpec)
+	if err != nil {
+		return err
+	}
+	policy, err := readPolicy(*policyPath)
+	if err != nil {
+		return err
+	}
+	var current []engine.Promotion
+	if *currentSpec != "" {
+		if current, err = engine.ParsePromotions(*currentSpec); err != nil {
+			return err
+		}
+	}
+
+	candidateReport, err := engine.Simulate(orders, candidate, policy)
+	if err != nil {
+		return err
+	}
+	currentReport, err := engine.Simulate(orders, current, policy)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "Candidate")
+	candidateReport.Print(w)
+	fmt.Fprintln(w, "\nCurrent")
+	currentReport.Print(w)
+	fmt.Fprintln(w, "\nDifference")
+	engine.PrintComparison(w, currentReport, candidateReport)
+	return nil
+}